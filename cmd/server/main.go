@@ -1,18 +1,29 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"time"
+	_ "time/tzdata"
 
+	"github.com/egoisthemain/pr-reviewer/internal/auth"
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
 	"github.com/egoisthemain/pr-reviewer/internal/handler"
+	"github.com/egoisthemain/pr-reviewer/internal/mailer"
 	"github.com/egoisthemain/pr-reviewer/internal/repository"
 	"github.com/egoisthemain/pr-reviewer/internal/repository/pg"
 	"github.com/egoisthemain/pr-reviewer/internal/service"
+	"github.com/egoisthemain/pr-reviewer/internal/vcs"
 
 	"github.com/gorilla/mux"
 )
 
+// slaLockKey is the advisory lock that elects the replica running the SLA
+// scheduler.
+const slaLockKey = 0x5052_534c41
+
 func main() {
 	dsn := os.Getenv("DB_DSN")
 	if dsn == "" {
@@ -30,19 +41,156 @@ func main() {
 
 	teamRepo := pg.NewTeamRepository(db)
 	prRepo := pg.NewPRRepository(db)
+	auditRepo := pg.NewAuditRepository(db)
+	exclRepo := pg.NewExclusionRepository(db)
+	statsRepo := pg.NewStatsRepository(db)
+	tokenRepo := pg.NewTokenRepository(db)
+	vcsRepo := pg.NewVCSRepository(db)
+	outboxRepo := pg.NewOutboxRepository(db)
+	webhookRepo := pg.NewWebhookRepository(db)
+	chatRepo := pg.NewChatRepository(db)
+	emailRepo := pg.NewEmailRepository(db)
+	slaRepo := pg.NewSLARepository(db)
+	txManager := pg.NewTxManager(db)
+
+	auditService := service.NewAuditService(auditRepo)
+	teamService := service.NewTeamService(teamRepo, auditService, txManager, outboxRepo)
+	exclService := service.NewExclusionService(exclRepo, teamRepo, auditService, txManager)
+	clients := make(map[domain.VCSProvider]vcs.Client)
+	vcsUsers := make(map[domain.VCSProvider]vcs.UserLookup)
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		clients[domain.ProviderGitHub] = vcs.NewGitHubClient(os.Getenv("GITHUB_API_URL"), token, vcs.DefaultBackoff)
+	}
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		gitlab := vcs.NewGitLabClient(os.Getenv("GITLAB_API_URL"), token, vcs.DefaultBackoff)
+		clients[domain.ProviderGitLab] = gitlab
+		vcsUsers[domain.ProviderGitLab] = gitlab
+	}
+	pusher := service.NewReviewerPusher(vcsRepo, prRepo, clients)
+	go pusher.Run(context.Background(), 30*time.Second)
+
+	prService := service.NewPRService(prRepo, teamRepo, exclRepo, auditService, pusher, txManager, outboxRepo)
+
+	statsService := service.NewStatsService(statsRepo, teamRepo)
+
+	scheduler := service.NewSLAScheduler(slaRepo, prService, txManager, pg.NewLeaderLock(db, slaLockKey))
+	go scheduler.Run(context.Background(), time.Minute)
+
+	var verifier *auth.Verifier
+	if src := os.Getenv("JWT_JWKS"); src != "" {
+		keys, err := auth.LoadKeySet(context.Background(), src)
+		if err != nil {
+			log.Fatalf("jwks: %v", err)
+		}
+		verifier = auth.NewVerifier(keys, auth.VerifierConfig{
+			Issuer:      os.Getenv("JWT_ISSUER"),
+			Audience:    os.Getenv("JWT_AUDIENCE"),
+			UserClaim:   os.Getenv("JWT_USER_CLAIM"),
+			RoleClaim:   os.Getenv("JWT_ROLE_CLAIM"),
+			DefaultRole: string(domain.TokenUser),
+		})
+	}
+	tokenService := service.NewTokenService(tokenRepo, auditService, txManager, os.Getenv("ADMIN_TOKEN"), verifier)
+
+	vcsService := service.NewVCSService(vcsRepo, prService, auditService, txManager, vcsUsers)
+
+	webhookService := service.NewWebhookService(webhookRepo, teamRepo, auditService, txManager)
+	go webhookService.Run(context.Background(), 5*time.Second)
+
+	chatNotifier := service.NewChatNotifier(chatRepo, teamRepo, prRepo, auditService, txManager, nil)
+	go chatNotifier.Run(context.Background(), 5*time.Second)
 
-	teamService := service.NewTeamService(teamRepo)
-	prService := service.NewPRService(prRepo, teamRepo)
+	sinks := []service.Sink{webhookService, chatNotifier}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		smtpClient, err := mailer.NewClient(mailer.Config{
+			Addr:     addr,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		})
+		if err != nil {
+			log.Fatalf("smtp: %v", err)
+		}
+		emailNotifier := service.NewEmailNotifier(emailRepo, teamRepo, smtpClient)
+		go emailNotifier.Run(context.Background(), 10*time.Second)
+		sinks = append(sinks, emailNotifier)
+	}
+
+	dispatcher := service.NewOutboxDispatcher(outboxRepo, sinks...)
+	go dispatcher.Run(context.Background(), time.Second)
 
-	h := handler.New(prService, teamService)
+	h := handler.New(prService, teamService, exclService, statsService, auditService, tokenService,
+		vcsService, dispatcher, webhookService, chatNotifier, scheduler, handler.WebhookConfig{
+			GitHubSecret: os.Getenv("GITHUB_WEBHOOK_SECRET"),
+			GitLabToken:  os.Getenv("GITLAB_WEBHOOK_TOKEN"),
+		})
 
 	router := mux.NewRouter()
+	router.Use(handler.RequestContext)
+	router.Use(h.Authenticate)
+
+	admin := handler.Allow(domain.TokenAdmin)
+	svc := handler.Allow(domain.TokenAdmin, domain.TokenService)
+	anyRole := handler.Allow(domain.TokenAdmin, domain.TokenService, domain.TokenUser)
+
+	router.Handle("/pullRequest/create", svc(h.CreatePR)).Methods("POST")
+	router.Handle("/pullRequest/merge", svc(h.MergePR)).Methods("POST")
+	router.Handle("/pullRequest/close", svc(h.ClosePR)).Methods("POST")
+	router.Handle("/pullRequest/reopen", svc(h.ReopenPR)).Methods("POST")
+	router.Handle("/pullRequest/reassign", anyRole(h.ReassignReviewer)).Methods("POST")
+	router.Handle("/pullRequest/addReviewer", svc(h.AddReviewer)).Methods("POST")
+	router.Handle("/pullRequest/removeReviewer", svc(h.RemoveReviewer)).Methods("POST")
+	router.Handle("/pullRequest/get", anyRole(h.GetPR)).Methods("GET")
+	router.Handle("/pullRequest/submitReview", anyRole(h.SubmitReview)).Methods("POST")
+	router.Handle("/pullRequest/remind", svc(h.RemindReviewers)).Methods("POST")
+	router.Handle("/sla/actions", svc(h.ListSLAActions)).Methods("GET")
+	router.Handle("/pullRequest/list", anyRole(h.ListPRs)).Methods("GET")
+	router.Handle("/users/getReview", anyRole(h.ListReviews)).Methods("GET")
+	router.Handle("/team/add", admin(h.CreateTeam)).Methods("POST")
+	router.Handle("/team/get", anyRole(h.GetTeam)).Methods("GET")
+	router.Handle("/team/rename", admin(h.RenameTeam)).Methods("POST")
+	router.Handle("/team/delete", admin(h.DeleteTeam)).Methods("POST")
+	router.Handle("/team/addMember", admin(h.AddTeamMember)).Methods("POST")
+	router.Handle("/team/removeMember", admin(h.RemoveTeamMember)).Methods("POST")
+	router.Handle("/team/setParent", admin(h.SetTeamParent)).Methods("POST")
+	router.Handle("/team/setShadowFraction", admin(h.SetShadowFraction)).Methods("POST")
+	router.Handle("/team/setReviewSLA", admin(h.SetReviewSLA)).Methods("POST")
+	router.Handle("/team/setChatChannel", admin(h.SetChatChannel)).Methods("POST")
+	router.Handle("/team/getChatChannel", admin(h.GetChatChannel)).Methods("GET")
+	router.Handle("/team/deleteChatChannel", admin(h.DeleteChatChannel)).Methods("POST")
+	router.Handle("/team/testChatChannel", admin(h.TestChatChannel)).Methods("POST")
+	router.Handle("/users/setIsActive", admin(h.SetUserActive)).Methods("POST")
+	router.Handle("/users/setRole", admin(h.SetUserRole)).Methods("POST")
+	router.Handle("/users/setUsername", admin(h.SetUsername)).Methods("POST")
+	router.Handle("/users/setEmail", admin(h.SetEmail)).Methods("POST")
+	router.Handle("/users/setWorkingHours", admin(h.SetWorkSchedule)).Methods("POST")
+	router.Handle("/users/move", admin(h.MoveUser)).Methods("POST")
+	router.Handle("/exclusions/add", admin(h.AddExclusion)).Methods("POST")
+	router.Handle("/exclusions/delete", admin(h.DeleteExclusion)).Methods("POST")
+	router.Handle("/exclusions/list", anyRole(h.ListExclusions)).Methods("GET")
+	router.Handle("/stats/reviewers", anyRole(h.ReviewerStats)).Methods("GET")
+	router.Handle("/stats/teams", anyRole(h.TeamStats)).Methods("GET")
+	router.Handle("/stats/fairness", anyRole(h.FairnessReport)).Methods("GET")
+	router.Handle("/audit/list", admin(h.ListAudit)).Methods("GET")
+	router.Handle("/tokens/create", admin(h.CreateToken)).Methods("POST")
+	router.Handle("/tokens/list", admin(h.ListTokens)).Methods("GET")
+	router.Handle("/tokens/revoke", admin(h.RevokeToken)).Methods("POST")
+	router.Handle("/vcs/identities/set", admin(h.SetVCSIdentity)).Methods("POST")
+	router.Handle("/vcs/identities/delete", admin(h.DeleteVCSIdentity)).Methods("POST")
+	router.Handle("/vcs/identities/list", admin(h.ListVCSIdentities)).Methods("GET")
+	router.Handle("/outbox/list", admin(h.ListOutbox)).Methods("GET")
+	router.Handle("/outbox/requeue", admin(h.RequeueOutbox)).Methods("POST")
+	router.Handle("/outbox/discard", admin(h.DiscardOutbox)).Methods("POST")
+	router.Handle("/subscriptions/create", admin(h.CreateSubscription)).Methods("POST")
+	router.Handle("/subscriptions/update", admin(h.UpdateSubscription)).Methods("POST")
+	router.Handle("/subscriptions/delete", admin(h.DeleteSubscription)).Methods("POST")
+	router.Handle("/subscriptions/list", admin(h.ListSubscriptions)).Methods("GET")
+	router.Handle("/subscriptions/deliveries", admin(h.ListDeliveries)).Methods("GET")
+	router.Handle("/subscriptions/redeliver", admin(h.Redeliver)).Methods("POST")
 
-	router.HandleFunc("/pullRequest/create", h.CreatePR).Methods("POST")
-	router.HandleFunc("/pullRequest/merge", h.MergePR).Methods("POST")
-	router.HandleFunc("/pullRequest/reassign", h.ReassignReviewer).Methods("POST")
-	router.HandleFunc("/users/getReview", h.ListReviews).Methods("GET")
-	router.HandleFunc("/team/add", h.CreateTeam).Methods("POST")
+	// Webhooks authenticate with the provider's signature instead of a token.
+	router.HandleFunc("/webhooks/github", h.GitHubWebhook).Methods("POST")
+	router.HandleFunc("/webhooks/gitlab", h.GitLabWebhook).Methods("POST")
 
 	router.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("ok"))
@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://issuer.test"
+	testAudience = "pr-reviewer"
+)
+
+// testKeys is a locally generated key set with one RSA and one EC key.
+type testKeys struct {
+	rsa *rsa.PrivateKey
+	ec  *ecdsa.PrivateKey
+}
+
+func newTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKeys{rsa: rk, ec: ek}
+}
+
+func (k testKeys) jwks(t *testing.T) []byte {
+	t.Helper()
+	b64 := base64.RawURLEncoding.EncodeToString
+	point, err := k.ec.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA", "kid": "rsa-1", "use": "sig",
+			"n": b64(k.rsa.N.Bytes()),
+			"e": b64(big.NewInt(int64(k.rsa.E)).Bytes()),
+		},
+		{
+			"kty": "EC", "kid": "ec-1", "use": "sig", "crv": "P-256",
+			"x": b64(point[1:33]),
+			"y": b64(point[33:]),
+		},
+		{"kty": "oct", "kid": "hmac-1", "k": "c2VjcmV0"},
+	}}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// sign builds a compact JWT. key is an *rsa.PrivateKey for RS256 or an
+// *ecdsa.PrivateKey for ES256, whatever alg the header claims.
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	signed := enc(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func validClaims(now time.Time) map[string]any {
+	return map[string]any{
+		"sub":  "u1",
+		"iss":  testIssuer,
+		"aud":  testAudience,
+		"exp":  now.Add(time.Hour).Unix(),
+		"iat":  now.Unix(),
+		"role": "admin",
+	}
+}
+
+func with(claims map[string]any, k string, v any) map[string]any {
+	out := make(map[string]any, len(claims))
+	for key, val := range claims {
+		out[key] = val
+	}
+	if v == nil {
+		delete(out, k)
+	} else {
+		out[k] = v
+	}
+	return out
+}
+
+func serveJWKS(t *testing.T, body []byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVerify(t *testing.T) {
+	keys := newTestKeys(t)
+	srv := serveJWKS(t, keys.jwks(t))
+
+	set, err := LoadKeySet(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	v := NewVerifier(set, VerifierConfig{
+		Issuer:      testIssuer,
+		Audience:    testAudience,
+		RoleClaim:   "role",
+		DefaultRole: "user",
+	})
+	v.now = func() time.Time { return now }
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := validClaims(now)
+
+	tests := []struct {
+		name     string
+		token    string
+		wantUser string
+		wantRole string
+	}{
+		{name: "valid RS256", token: sign(t, "RS256", "rsa-1", keys.rsa, claims), wantUser: "u1", wantRole: "admin"},
+		{name: "valid ES256", token: sign(t, "ES256", "ec-1", keys.ec, claims), wantUser: "u1", wantRole: "admin"},
+		{name: "audience list", token: sign(t, "RS256", "rsa-1", keys.rsa,
+			with(claims, "aud", []string{"other", testAudience})), wantUser: "u1", wantRole: "admin"},
+		{name: "default role", token: sign(t, "RS256", "rsa-1", keys.rsa,
+			with(claims, "role", nil)), wantUser: "u1", wantRole: "user"},
+		{name: "expiry within leeway", token: sign(t, "RS256", "rsa-1", keys.rsa,
+			with(claims, "exp", now.Add(-leeway/2).Unix())), wantUser: "u1", wantRole: "admin"},
+
+		{name: "expired", token: sign(t, "RS256", "rsa-1", keys.rsa,
+			with(claims, "exp", now.Add(-time.Hour).Unix()))},
+		{name: "missing exp", token: sign(t, "RS256", "rsa-1", keys.rsa, with(claims, "exp", nil))},
+		{name: "not yet valid", token: sign(t, "RS256", "rsa-1", keys.rsa,
+			with(claims, "nbf", now.Add(time.Hour).Unix()))},
+		{name: "wrong audience", token: sign(t, "RS256", "rsa-1", keys.rsa, with(claims, "aud", "someone-else"))},
+		{name: "wrong issuer", token: sign(t, "RS256", "rsa-1", keys.rsa, with(claims, "iss", "https://evil.test"))},
+		{name: "missing subject", token: sign(t, "RS256", "rsa-1", keys.rsa, with(claims, "sub", nil))},
+		{name: "unknown kid", token: sign(t, "RS256", "rsa-2", keys.rsa, claims)},
+		{name: "non-signing key", token: sign(t, "RS256", "hmac-1", keys.rsa, claims)},
+		{name: "bad signature", token: sign(t, "RS256", "rsa-1", other, claims)},
+		{name: "RSA key with ES256", token: sign(t, "ES256", "rsa-1", keys.ec, claims)},
+		{name: "EC key with RS256", token: sign(t, "RS256", "ec-1", keys.rsa, claims)},
+		{name: "EC key with ES384", token: sign(t, "ES384", "ec-1", keys.ec, claims)},
+		{name: "alg none", token: sign(t, "none", "rsa-1", keys.rsa, claims)},
+		{name: "alg HS256", token: sign(t, "HS256", "rsa-1", keys.rsa, claims)},
+		{name: "malformed", token: "not.a.jwt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := v.Verify(context.Background(), tt.token)
+			if tt.wantUser == "" {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("Verify() = %+v, %v; want ErrInvalidToken", id, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify(): %v", err)
+			}
+			if id.UserID != tt.wantUser || id.Role != tt.wantRole {
+				t.Fatalf("Verify() = %+v; want user %q role %q", id, tt.wantUser, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestVerifyTamperedClaims(t *testing.T) {
+	keys := newTestKeys(t)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, keys.jwks(t), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	set, err := LoadKeySet(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := NewVerifier(set, VerifierConfig{RoleClaim: "role", DefaultRole: "user"})
+
+	now := time.Now()
+	token := sign(t, "ES256", "ec-1", keys.ec, with(validClaims(now), "role", "user"))
+	if _, err := v.Verify(context.Background(), token); err != nil {
+		t.Fatalf("Verify() untampered: %v", err)
+	}
+
+	forged := sign(t, "ES256", "ec-1", keys.ec, validClaims(now))
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+	if _, err := v.Verify(context.Background(), tampered); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Verify() tampered = %v; want ErrInvalidToken", err)
+	}
+}
+
+func TestKeySetReloadsOnUnknownKid(t *testing.T) {
+	keys := newTestKeys(t)
+	rolled := newTestKeys(t)
+
+	var body atomic.Value
+	body.Store(keys.jwks(t))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body.Load().([]byte))
+	}))
+	defer srv.Close()
+
+	set, err := LoadKeySet(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The issuer rolls its RSA key under a new kid. A set loaded less than
+	// minRefreshInterval ago is not reloaded yet.
+	var doc struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(rolled.jwks(t), &doc); err != nil {
+		t.Fatal(err)
+	}
+	doc.Keys[0]["kid"] = "rsa-2"
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body.Store(b)
+
+	if _, err := set.Key(context.Background(), "rsa-2"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Key() before refresh interval = %v; want ErrUnknownKey", err)
+	}
+
+	set.mu.Lock()
+	set.loadedAt = time.Now().Add(-minRefreshInterval)
+	set.mu.Unlock()
+
+	key, err := set.Key(context.Background(), "rsa-2")
+	if err != nil {
+		t.Fatalf("Key() after refresh interval: %v", err)
+	}
+	if !rolled.rsa.PublicKey.Equal(key) {
+		t.Fatal("Key() returned a key other than the rolled one")
+	}
+}
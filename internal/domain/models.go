@@ -1,17 +1,80 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+type UserRole string
+
+const (
+	RoleMember UserRole = "MEMBER"
+	// RoleShadow marks juniors who shadow reviews for onboarding. They are
+	// assigned on top of the regular reviewers and never replace them.
+	RoleShadow UserRole = "SHADOW"
+)
 
 type User struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	TeamName string `json:"team_name,omitempty"`
-	IsActive bool   `json:"is_active"`
+	UserID   string   `json:"user_id"`
+	Username string   `json:"username"`
+	Email    string   `json:"email,omitempty"`
+	TeamName string   `json:"team_name,omitempty"`
+	Teams    []string `json:"teams,omitempty"`
+	IsActive bool     `json:"is_active"`
+	Role     UserRole `json:"role,omitempty"`
+	WorkSchedule
 }
 
 type Team struct {
 	TeamName string `json:"team_name"`
 	Members  []User `json:"members"`
+	// ShadowFraction is the share of the team's PRs, from 0 to 1, that get a
+	// shadow reviewer.
+	ShadowFraction float64 `json:"shadow_fraction"`
+	ParentTeam     string  `json:"parent_team_name,omitempty"`
+	Subteams       []Team  `json:"subteams,omitempty"`
+	// ReviewSLA applies to the PRs of the team's members.
+	ReviewSLA ReviewSLA `json:"review_sla"`
+}
+
+// ReviewSLA sets how long a reviewer may leave a PR without a decision before
+// being reminded and before being replaced. Zero disables the step.
+type ReviewSLA struct {
+	ReminderMinutes int `json:"reminder_minutes"`
+	ReassignMinutes int `json:"reassign_minutes"`
+}
+
+type SLAActionType string
+
+const (
+	SLAReminder SLAActionType = "REMINDER"
+	SLAReassign SLAActionType = "REASSIGN"
+)
+
+// SLAAction is a reminder or reassignment made by the SLA scheduler.
+// AssignedAt identifies the assignment it acted on.
+type SLAAction struct {
+	ID            int64         `json:"id"`
+	PullRequestID string        `json:"pull_request_id"`
+	UserID        string        `json:"user_id"`
+	Action        SLAActionType `json:"action"`
+	AssignedAt    time.Time     `json:"assigned_at"`
+	NewUserID     string        `json:"new_user_id,omitempty"`
+	Error         string        `json:"error,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// PendingReview is an assignment on an open PR still waiting for a decision,
+// with the strictest SLA of the author's teams.
+type PendingReview struct {
+	PullRequestID string
+	UserID        string
+	AssignedAt    time.Time
+	SLA           ReviewSLA
+	// Schedule is the reviewer's; SLA time counts only their working hours.
+	Schedule  WorkSchedule
+	Reminded  bool
+	Escalated bool
 }
 
 type PRStatus string
@@ -19,6 +82,7 @@ type PRStatus string
 const (
 	PROpen   PRStatus = "OPEN"
 	PRMerged PRStatus = "MERGED"
+	PRClosed PRStatus = "CLOSED"
 )
 
 type PullRequest struct {
@@ -28,5 +92,366 @@ type PullRequest struct {
 	Status            PRStatus   `json:"status"`
 	CreatedAt         time.Time  `json:"created_at"`
 	MergedAt          *time.Time `json:"merged_at,omitempty"`
+	ClosedAt          *time.Time `json:"closed_at,omitempty"`
 	AssignedReviewers []string   `json:"assigned_reviewers"`
+	ShadowReviewers   []string   `json:"shadow_reviewers,omitempty"`
+	// Version grows on every change of the PR or its reviewers.
+	Version int64 `json:"-"`
+}
+
+type PRReviewer struct {
+	UserID     string         `json:"user_id"`
+	Username   string         `json:"username"`
+	Role       string         `json:"role"`
+	AssignedAt time.Time      `json:"assigned_at"`
+	Decision   ReviewDecision `json:"decision,omitempty"`
+	DecidedAt  *time.Time     `json:"decided_at,omitempty"`
+}
+
+// PRDetails is a PR with its reviewers' decisions and the teams of its author.
+type PRDetails struct {
+	PullRequest *PullRequest `json:"pull_request"`
+	Reviewers   []PRReviewer `json:"reviewers"`
+	AuthorTeams []string     `json:"author_teams,omitempty"`
+}
+
+type ReviewDecision string
+
+const (
+	DecisionApproved         ReviewDecision = "APPROVED"
+	DecisionChangesRequested ReviewDecision = "CHANGES_REQUESTED"
+)
+
+type PRSort string
+
+const (
+	SortCreatedAsc  PRSort = "created_at"
+	SortCreatedDesc PRSort = "-created_at"
+	SortIDAsc       PRSort = "pull_request_id"
+	SortIDDesc      PRSort = "-pull_request_id"
+)
+
+// PRCursor is the position after the last PR of a page.
+type PRCursor struct {
+	CreatedAt     time.Time `json:"c"`
+	PullRequestID string    `json:"id"`
+}
+
+type PRListFilter struct {
+	Status     PRStatus
+	AuthorID   string
+	ReviewerID string
+	// PendingReview keeps only PRs on which ReviewerID has not submitted a
+	// decision yet.
+	PendingReview bool
+	// AssignedOnly leaves out PRs on which ReviewerID is only a shadow
+	// reviewer.
+	AssignedOnly bool
+	TeamName     string
+	CreatedFrom  *time.Time
+	CreatedTo    *time.Time
+	Sort         PRSort
+	After        *PRCursor
+	Limit        int
+}
+
+type PREventType string
+
+const (
+	EventReviewerAssigned       PREventType = "ASSIGNED"
+	EventReviewerReassignedAway PREventType = "REASSIGNED_AWAY"
+	EventReviewerRemoved        PREventType = "REMOVED"
+	EventPRMerged               PREventType = "MERGED"
+	EventReviewSubmitted        PREventType = "REVIEWED"
+	EventPRClosed               PREventType = "CLOSED"
+	EventPRReopened             PREventType = "REOPENED"
+)
+
+type ReviewerStats struct {
+	UserID         string `json:"user_id"`
+	Username       string `json:"username"`
+	Assignments    int    `json:"assignments"`
+	OpenReviews    int    `json:"open_reviews"`
+	ReassignedAway int    `json:"reassigned_away"`
+	// MedianTimeToMerge is in seconds, from assignment to merge, over the
+	// PRs merged inside the window. Nil when there are none.
+	MedianTimeToMerge *float64 `json:"median_time_to_merge_seconds"`
+}
+
+type WeeklyThroughput struct {
+	WeekStart time.Time `json:"week_start"`
+	Created   int       `json:"created"`
+	Merged    int       `json:"merged"`
+}
+
+// TeamStats describes the PRs authored by members of a team. Cycle times are
+// in seconds from creation to merge, over the PRs merged inside the window.
+type TeamStats struct {
+	TeamName         string             `json:"team_name"`
+	Created          int                `json:"created"`
+	Merged           int                `json:"merged"`
+	MedianCycleTime  *float64           `json:"median_cycle_time_seconds"`
+	P90CycleTime     *float64           `json:"p90_cycle_time_seconds"`
+	WithoutReviewers int                `json:"without_reviewers"`
+	Reassignments    int                `json:"reassignments"`
+	Weeks            []WeeklyThroughput `json:"weeks"`
+}
+
+type ReviewerLoad struct {
+	TeamName    string `json:"-"`
+	UserID      string `json:"user_id"`
+	Username    string `json:"username"`
+	Assignments int    `json:"assignments"`
+}
+
+// TeamFairness measures how evenly a team's active members were assigned as
+// reviewers. MaxMinRatio is nil when someone got no assignments at all.
+type TeamFairness struct {
+	TeamName         string         `json:"team_name"`
+	ActiveMembers    int            `json:"active_members"`
+	TotalAssignments int            `json:"total_assignments"`
+	Mean             float64        `json:"mean"`
+	Gini             float64        `json:"gini"`
+	MaxMinRatio      *float64       `json:"max_min_ratio"`
+	Skewed           bool           `json:"skewed"`
+	Overloaded       []ReviewerLoad `json:"overloaded"`
+	Underused        []ReviewerLoad `json:"underused"`
+}
+
+// StatsFilter limits statistics to [From, To) and, when Teams is not empty,
+// to members of those teams.
+type StatsFilter struct {
+	From  time.Time
+	To    time.Time
+	Teams []string
+}
+
+type VCSProvider string
+
+const (
+	ProviderGitHub VCSProvider = "github"
+	ProviderGitLab VCSProvider = "gitlab"
+)
+
+// VCSIdentity maps a login on a VCS provider to a user of the service.
+type VCSIdentity struct {
+	Provider VCSProvider `json:"provider"`
+	Login    string      `json:"login"`
+	UserID   string      `json:"user_id"`
+}
+
+type VCSAction string
+
+const (
+	VCSOpened   VCSAction = "opened"
+	VCSMerged   VCSAction = "merged"
+	VCSClosed   VCSAction = "closed"
+	VCSReopened VCSAction = "reopened"
+)
+
+// VCSEvent is a pull or merge request webhook reduced to what the service
+// acts on, whatever provider it came from.
+type VCSEvent struct {
+	Provider    VCSProvider
+	DeliveryID  string
+	Action      VCSAction
+	Repository  string
+	Number      int
+	Title       string
+	AuthorLogin string
+	// AuthorID is the provider's numeric ID of the author, for payloads that
+	// do not carry the author's login.
+	AuthorID int
+}
+
+// VCSPush is a queued push of a PR's reviewers to its provider.
+type VCSPush struct {
+	PullRequestID string
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	// Generation grows every time the PR is queued again.
+	Generation int64
+}
+
+type DomainEventType string
+
+const (
+	DomainPRCreated          DomainEventType = "pr.created"
+	DomainReviewerAssigned   DomainEventType = "pr.reviewer_assigned"
+	DomainReviewerReassigned DomainEventType = "pr.reviewer_reassigned"
+	DomainReviewerRemoved    DomainEventType = "pr.reviewer_removed"
+	DomainReviewSubmitted    DomainEventType = "pr.review_submitted"
+	DomainPRMerged           DomainEventType = "pr.merged"
+	DomainPRClosed           DomainEventType = "pr.closed"
+	DomainPRReopened         DomainEventType = "pr.reopened"
+	DomainUserDeactivated    DomainEventType = "user.deactivated"
+	DomainReviewReminder     DomainEventType = "pr.review_reminder"
+)
+
+// DomainEventTypes lists every event type the outbox carries.
+var DomainEventTypes = []DomainEventType{
+	DomainPRCreated, DomainReviewerAssigned, DomainReviewerReassigned, DomainReviewerRemoved,
+	DomainReviewSubmitted, DomainPRMerged, DomainPRClosed, DomainPRReopened, DomainUserDeactivated,
+	DomainReviewReminder,
+}
+
+// EventPayload is the body of a domain event. PR events carry the PR as it
+// was right after the change.
+type EventPayload struct {
+	PullRequest *PullRequest   `json:"pull_request,omitempty"`
+	User        *User          `json:"user,omitempty"`
+	UserID      string         `json:"user_id,omitempty"`
+	OldUserID   string         `json:"old_user_id,omitempty"`
+	Decision    ReviewDecision `json:"decision,omitempty"`
+}
+
+type OutboxStatus string
+
+const (
+	OutboxPending   OutboxStatus = "PENDING"
+	OutboxDelivered OutboxStatus = "DELIVERED"
+	OutboxDead      OutboxStatus = "DEAD"
+	OutboxDiscarded OutboxStatus = "DISCARDED"
+)
+
+type OutboxEvent struct {
+	ID          int64           `json:"id"`
+	Type        DomainEventType `json:"event_type"`
+	AggregateID string          `json:"aggregate_id"`
+	Payload     EventPayload    `json:"payload"`
+	Status      OutboxStatus    `json:"status"`
+	Attempts    int             `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	// DeliveredSinks names the sinks that have accepted the event.
+	DeliveredSinks []string `json:"delivered_sinks,omitempty"`
+}
+
+// Email is a queued notification email.
+type Email struct {
+	ID       int64
+	EventID  int64
+	To       string
+	Subject  string
+	Text     string
+	HTML     string
+	Attempts int
+}
+
+// TeamChatChannel is where a team's chat notifications go. Empty templates
+// fall back to the built-in ones.
+type TeamChatChannel struct {
+	TeamName            string    `json:"team_name"`
+	WebhookURL          string    `json:"webhook_url"`
+	AssignedTemplate    string    `json:"assigned_template,omitempty"`
+	DeactivatedTemplate string    `json:"deactivated_template,omitempty"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// ChatDelivery is a rendered chat message queued for a team's channel.
+type ChatDelivery struct {
+	ID         int64
+	TeamName   string
+	EventID    int64
+	Text       string
+	WebhookURL string
+	Attempts   int
+}
+
+// WebhookSubscription sends the events listed in Events, or all events when
+// it is empty, to URL. With TeamName set only events about the team's
+// members' PRs, or the members themselves, are sent.
+type WebhookSubscription struct {
+	ID        int64             `json:"id"`
+	URL       string            `json:"url"`
+	Events    []DomainEventType `json:"events"`
+	TeamName  string            `json:"team_name,omitempty"`
+	Secret    string            `json:"-"`
+	Active    bool              `json:"active"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "PENDING"
+	DeliveryDelivered WebhookDeliveryStatus = "DELIVERED"
+	DeliveryFailed    WebhookDeliveryStatus = "FAILED"
+)
+
+type WebhookDelivery struct {
+	ID             int64                 `json:"id"`
+	SubscriptionID int64                 `json:"subscription_id"`
+	EventID        int64                 `json:"event_id"`
+	EventType      DomainEventType       `json:"event_type"`
+	Payload        json.RawMessage       `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	LastStatusCode int                   `json:"last_status_code,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at"`
+	CreatedAt      time.Time             `json:"created_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+}
+
+// WebhookPayload is the body of an outgoing webhook.
+type WebhookPayload struct {
+	EventID   int64           `json:"event_id"`
+	Event     DomainEventType `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      EventPayload    `json:"data"`
+}
+
+type TokenRole string
+
+const (
+	TokenAdmin   TokenRole = "admin"
+	TokenService TokenRole = "service"
+	// TokenUser acts on behalf of UserID and may only touch that user's PRs.
+	TokenUser TokenRole = "user"
+)
+
+type APIToken struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Role      TokenRole  `json:"role"`
+	UserID    string     `json:"user_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   string          `json:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	RequestID  string          `json:"request_id"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	EntityType string
+	EntityID   string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+// ReviewerExclusion forbids ReviewerID from reviewing PRs of AuthorID, or of
+// any member of TeamName. Exactly one of AuthorID and TeamName is set.
+type ReviewerExclusion struct {
+	ID         int64     `json:"id"`
+	AuthorID   string    `json:"author_id,omitempty"`
+	TeamName   string    `json:"team_name,omitempty"`
+	ReviewerID string    `json:"reviewer_id"`
+	Reason     string    `json:"reason,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
 }
@@ -0,0 +1,166 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WorkSchedule says when a user works. Without WorkingHours the user counts as
+// always available; an empty Timezone means UTC.
+type WorkSchedule struct {
+	Timezone     string        `json:"timezone,omitempty"`
+	WorkingHours *WorkingHours `json:"working_hours,omitempty"`
+}
+
+// WorkingHours is a daily "15:04" interval on the listed days, "mon" to
+// "sun". No days means Monday to Friday. End may be "24:00".
+type WorkingHours struct {
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Days  []string `json:"days,omitempty"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Check reports whether the timezone is known and the hours are well formed.
+func (s WorkSchedule) Check() error {
+	if _, err := s.location(); err != nil {
+		return err
+	}
+	if s.WorkingHours != nil {
+		if _, err := s.WorkingHours.parse(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InWorkingHours reports whether t falls in the working hours. A schedule
+// that cannot be parsed counts as always working.
+func (s WorkSchedule) InWorkingHours(t time.Time) bool {
+	loc, h, ok := s.resolve()
+	if !ok {
+		return true
+	}
+
+	lt := t.In(loc)
+	m := lt.Hour()*60 + lt.Minute()
+	return h.days[lt.Weekday()] && m >= h.start && m < h.end
+}
+
+// WorkingTime returns how much of [from, to) falls in the working hours, or
+// all of it without a schedule.
+func (s WorkSchedule) WorkingTime(from, to time.Time) time.Duration {
+	if !to.After(from) {
+		return 0
+	}
+	loc, h, ok := s.resolve()
+	if !ok {
+		return to.Sub(from)
+	}
+
+	var total time.Duration
+	lf := from.In(loc)
+	for day := time.Date(lf.Year(), lf.Month(), lf.Day(), 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !h.days[day.Weekday()] {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, h.start, 0, 0, loc)
+		end := time.Date(day.Year(), day.Month(), day.Day(), 0, h.end, 0, 0, loc)
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
+
+// locations caches loaded timezones by name: time.LoadLocation reads the
+// zone file on every call, and schedules are checked for every candidate
+// reviewer and every pending SLA.
+var locations sync.Map
+
+func (s WorkSchedule) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := locations.Load(s.Timezone); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(s.Timezone, loc)
+	return loc, nil
+}
+
+func (s WorkSchedule) resolve() (*time.Location, workingHours, bool) {
+	if s.WorkingHours == nil {
+		return nil, workingHours{}, false
+	}
+	loc, err := s.location()
+	if err != nil {
+		return nil, workingHours{}, false
+	}
+	h, err := s.WorkingHours.parse()
+	if err != nil {
+		return nil, workingHours{}, false
+	}
+	return loc, h, true
+}
+
+// workingHours is WorkingHours in minutes since midnight.
+type workingHours struct {
+	start, end int
+	days       [7]bool
+}
+
+func (w WorkingHours) parse() (workingHours, error) {
+	var h workingHours
+	var err error
+	if h.start, err = parseClock(w.Start); err != nil {
+		return h, err
+	}
+	if h.end, err = parseClock(w.End); err != nil {
+		return h, err
+	}
+	if h.start >= h.end {
+		return h, errors.New("working hours must end after they start")
+	}
+
+	if len(w.Days) == 0 {
+		for d := time.Monday; d <= time.Friday; d++ {
+			h.days[d] = true
+		}
+	}
+	for _, name := range w.Days {
+		d, ok := weekdays[name]
+		if !ok {
+			return h, fmt.Errorf("unknown day %q", name)
+		}
+		h.days[d] = true
+	}
+	return h, nil
+}
+
+func parseClock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func at(t *testing.T, tz, value string) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestWorkingTime(t *testing.T) {
+	office := &WorkingHours{Start: "09:00", End: "18:00"}
+	everyDay := []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+	tests := []struct {
+		name     string
+		schedule WorkSchedule
+		tz       string
+		from, to string
+		want     time.Duration
+	}{
+		{
+			name:     "no working hours",
+			schedule: WorkSchedule{},
+			tz:       "UTC", from: "2026-03-06 16:00", to: "2026-03-09 11:00",
+			want: 67 * time.Hour,
+		},
+		{
+			name:     "weekend span with empty days",
+			schedule: WorkSchedule{WorkingHours: office},
+			tz:       "UTC", from: "2026-03-06 16:00", to: "2026-03-09 11:00",
+			want: 4 * time.Hour,
+		},
+		{
+			name:     "weekend only",
+			schedule: WorkSchedule{WorkingHours: office},
+			tz:       "UTC", from: "2026-03-07 00:00", to: "2026-03-09 00:00",
+			want: 0,
+		},
+		{
+			name:     "listed days",
+			schedule: WorkSchedule{WorkingHours: &WorkingHours{Start: "09:00", End: "18:00", Days: []string{"sat"}}},
+			tz:       "UTC", from: "2026-03-06 00:00", to: "2026-03-09 00:00",
+			want: 9 * time.Hour,
+		},
+		{
+			name:     "in the user's timezone",
+			schedule: WorkSchedule{Timezone: "Asia/Tokyo", WorkingHours: office},
+			tz:       "UTC", from: "2026-03-09 00:00", to: "2026-03-09 12:00",
+			want: 9 * time.Hour,
+		},
+		{
+			name:     "24:00 end",
+			schedule: WorkSchedule{WorkingHours: &WorkingHours{Start: "22:00", End: "24:00"}},
+			tz:       "UTC", from: "2026-03-09 21:00", to: "2026-03-10 01:00",
+			want: 2 * time.Hour,
+		},
+		{
+			name:     "DST starts inside the hours",
+			schedule: WorkSchedule{Timezone: "Europe/Berlin", WorkingHours: &WorkingHours{Start: "01:00", End: "04:00", Days: everyDay}},
+			tz:       "Europe/Berlin", from: "2026-03-29 00:00", to: "2026-03-30 00:00",
+			want: 2 * time.Hour,
+		},
+		{
+			name:     "whole day as DST starts",
+			schedule: WorkSchedule{Timezone: "Europe/Berlin", WorkingHours: &WorkingHours{Start: "00:00", End: "24:00", Days: everyDay}},
+			tz:       "Europe/Berlin", from: "2026-03-29 00:00", to: "2026-03-30 00:00",
+			want: 23 * time.Hour,
+		},
+		{
+			name:     "whole day as DST ends",
+			schedule: WorkSchedule{Timezone: "Europe/Berlin", WorkingHours: &WorkingHours{Start: "00:00", End: "24:00", Days: everyDay}},
+			tz:       "Europe/Berlin", from: "2026-10-25 00:00", to: "2026-10-26 00:00",
+			want: 25 * time.Hour,
+		},
+		{
+			name:     "unknown timezone counts as always working",
+			schedule: WorkSchedule{Timezone: "Mars/Olympus", WorkingHours: office},
+			tz:       "UTC", from: "2026-03-07 00:00", to: "2026-03-07 05:00",
+			want: 5 * time.Hour,
+		},
+		{
+			name:     "empty interval",
+			schedule: WorkSchedule{WorkingHours: office},
+			tz:       "UTC", from: "2026-03-09 12:00", to: "2026-03-09 10:00",
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.schedule.WorkingTime(at(t, tt.tz, tt.from), at(t, tt.tz, tt.to))
+			if got != tt.want {
+				t.Fatalf("WorkingTime() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInWorkingHours(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule WorkSchedule
+		at       string
+		want     bool
+	}{
+		{"no working hours", WorkSchedule{}, "2026-03-07 03:00", true},
+		{"weekday with empty days", WorkSchedule{WorkingHours: &WorkingHours{Start: "09:00", End: "18:00"}}, "2026-03-09 10:00", true},
+		{"weekend with empty days", WorkSchedule{WorkingHours: &WorkingHours{Start: "09:00", End: "18:00"}}, "2026-03-07 10:00", false},
+		{"end is exclusive", WorkSchedule{WorkingHours: &WorkingHours{Start: "09:00", End: "18:00"}}, "2026-03-09 18:00", false},
+		{"24:00 end", WorkSchedule{WorkingHours: &WorkingHours{Start: "22:00", End: "24:00"}}, "2026-03-09 23:59", true},
+		{"in the user's timezone", WorkSchedule{Timezone: "Asia/Tokyo", WorkingHours: &WorkingHours{Start: "09:00", End: "18:00"}}, "2026-03-09 01:00", true},
+		{"weekday in UTC, Saturday in the user's timezone", WorkSchedule{Timezone: "Pacific/Auckland", WorkingHours: &WorkingHours{Start: "00:00", End: "24:00"}}, "2026-03-06 12:00", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.InWorkingHours(at(t, "UTC", tt.at)); got != tt.want {
+				t.Fatalf("InWorkingHours() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
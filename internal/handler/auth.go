@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+	"github.com/egoisthemain/pr-reviewer/internal/reqctx"
+	"github.com/egoisthemain/pr-reviewer/internal/service"
+)
+
+// Authenticate resolves the bearer token, if any, and stores it in the request
+// context. Rejecting anonymous calls is left to Allow, so open routes such as
+// /healthz keep working.
+func (h *Handler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		secret, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			writeErr(w, service.ErrUnauthorized)
+			return
+		}
+
+		t, err := h.tokens.Authenticate(r.Context(), secret)
+		if err != nil {
+			writeErr(w, err)
+			return
+		}
+
+		ctx := reqctx.WithToken(r.Context(), t)
+		ctx = reqctx.WithActor(ctx, tokenActor(t))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// tokenActor names the caller in the audit log: the user for user tokens,
+// the token name otherwise.
+func tokenActor(t *domain.APIToken) string {
+	if t.UserID != "" {
+		return t.UserID
+	}
+	return "token:" + t.Name
+}
+
+// Allow wraps a handler so that only tokens with one of roles may call it.
+func Allow(roles ...domain.TokenRole) func(http.HandlerFunc) http.Handler {
+	return func(next http.HandlerFunc) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t := reqctx.Token(r.Context())
+			if t == nil {
+				writeErr(w, service.ErrUnauthorized)
+				return
+			}
+			for _, role := range roles {
+				if t.Role == role {
+					next(w, r)
+					return
+				}
+			}
+			writeErr(w, service.ErrForbidden)
+		})
+	}
+}
+
+func (h *Handler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	var req domain.APIToken
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	secret, t, err := h.tokens.Create(r.Context(), req)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":  t,
+		"secret": secret,
+	})
+}
+
+func (h *Handler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	list, err := h.tokens.List(r.Context())
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tokens": list,
+	})
+}
+
+func (h *Handler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		ID int64 `json:"id"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := h.tokens.Revoke(r.Context(), req.ID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token": t,
+	})
+}
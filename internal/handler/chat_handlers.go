@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type teamNameReq struct {
+	TeamName string `json:"team_name"`
+}
+
+func (h *Handler) SetChatChannel(w http.ResponseWriter, r *http.Request) {
+	var req domain.TeamChatChannel
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c, err := h.chat.SetChannel(r.Context(), req)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel": c,
+	})
+}
+
+func (h *Handler) GetChatChannel(w http.ResponseWriter, r *http.Request) {
+	teamName := r.URL.Query().Get("team_name")
+	if teamName == "" {
+		http.Error(w, "team_name is required", http.StatusBadRequest)
+		return
+	}
+
+	c, err := h.chat.GetChannel(r.Context(), teamName)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel": c,
+	})
+}
+
+func (h *Handler) DeleteChatChannel(w http.ResponseWriter, r *http.Request) {
+	var req teamNameReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.chat.DeleteChannel(r.Context(), req.TeamName); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestChatChannel posts a sample message, so that a channel or a template can
+// be checked without creating a PR.
+func (h *Handler) TestChatChannel(w http.ResponseWriter, r *http.Request) {
+	var req teamNameReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.chat.Test(r.Context(), req.TeamName); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+func (h *Handler) AddExclusion(w http.ResponseWriter, r *http.Request) {
+	var req domain.ReviewerExclusion
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	e, err := h.excl.Add(r.Context(), req)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"exclusion": e,
+	})
+}
+
+func (h *Handler) DeleteExclusion(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		ID int64 `json:"id"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.excl.Delete(r.Context(), req.ID); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ListExclusions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	list, err := h.excl.List(r.Context(), q.Get("user_id"), q.Get("team_name"))
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"exclusions": list,
+	})
+}
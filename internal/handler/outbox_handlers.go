@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+func (h *Handler) ListOutbox(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit, err := parseIntParam(q.Get("limit"))
+	if err != nil {
+		http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, err := h.outbox.List(r.Context(), domain.OutboxStatus(q.Get("status")), limit)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events": list,
+	})
+}
+
+func (h *Handler) RequeueOutbox(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		ID int64 `json:"id"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.outbox.Requeue(r.Context(), req.ID); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) DiscardOutbox(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		ID int64 `json:"id"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.outbox.Discard(r.Context(), req.ID); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,29 +2,59 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+	"github.com/egoisthemain/pr-reviewer/internal/repository/pg"
 	"github.com/egoisthemain/pr-reviewer/internal/service"
 )
 
 type Handler struct {
-	pr *service.PRService
-	tm *service.TeamService
+	pr       *service.PRService
+	tm       *service.TeamService
+	excl     *service.ExclusionService
+	stats    *service.StatsService
+	audit    *service.AuditService
+	tokens   *service.TokenService
+	vcs      *service.VCSService
+	outbox   *service.OutboxDispatcher
+	webhooks *service.WebhookService
+	chat     *service.ChatNotifier
+	sla      *service.SLAScheduler
+	hooks    WebhookConfig
 }
 
-func New(pr *service.PRService, tm *service.TeamService) *Handler {
-	return &Handler{pr: pr, tm: tm}
+// WebhookConfig holds the shared secrets of the VCS webhooks. An empty secret
+// disables its webhook.
+type WebhookConfig struct {
+	GitHubSecret string
+	GitLabToken  string
+}
+
+func New(pr *service.PRService, tm *service.TeamService, excl *service.ExclusionService,
+	stats *service.StatsService, audit *service.AuditService, tokens *service.TokenService,
+	vcs *service.VCSService, outbox *service.OutboxDispatcher, webhooks *service.WebhookService,
+	chat *service.ChatNotifier, sla *service.SLAScheduler, hooks WebhookConfig) *Handler {
+	return &Handler{pr: pr, tm: tm, excl: excl, stats: stats, audit: audit, tokens: tokens,
+		vcs: vcs, outbox: outbox, webhooks: webhooks, chat: chat, sla: sla, hooks: hooks}
 }
 
 func (h *Handler) CreatePR(w http.ResponseWriter, r *http.Request) {
 	type Req struct {
-		PullRequestID   string `json:"pull_request_id"`
-		PullRequestName string `json:"pull_request_name"`
-		AuthorID        string `json:"author_id"`
+		PullRequestID      string   `json:"pull_request_id"`
+		PullRequestName    string   `json:"pull_request_name"`
+		AuthorID           string   `json:"author_id"`
+		RequestedReviewers []string `json:"requested_reviewers,omitempty"`
 	}
 
 	type Resp struct {
-		PR interface{} `json:"pull_request"`
+		PR                    interface{} `json:"pull_request"`
+		RequestedReviewers    []string    `json:"requested_reviewers"`
+		AutoAssignedReviewers []string    `json:"auto_assigned_reviewers"`
 	}
 
 	var req Req
@@ -33,13 +63,20 @@ func (h *Handler) CreatePR(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pr, err := h.pr.CreatePR(r.Context(), req.PullRequestID, req.PullRequestName, req.AuthorID)
+	pr, auto, err := h.pr.CreatePR(r.Context(), req.PullRequestID, req.PullRequestName, req.AuthorID, req.RequestedReviewers)
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeErr(w, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(Resp{PR: pr})
+	requested := make([]string, 0, len(pr.AssignedReviewers))
+	for _, id := range pr.AssignedReviewers {
+		if !contains(auto, id) {
+			requested = append(requested, id)
+		}
+	}
+
+	json.NewEncoder(w).Encode(Resp{PR: pr, RequestedReviewers: requested, AutoAssignedReviewers: auto})
 }
 
 func (h *Handler) MergePR(w http.ResponseWriter, r *http.Request) {
@@ -59,59 +96,327 @@ func (h *Handler) MergePR(w http.ResponseWriter, r *http.Request) {
 
 	pr, err := h.pr.MergePR(r.Context(), req.PullRequestID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeErr(w, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(Resp{PR: pr})
 }
 
+type prReq struct {
+	PullRequestID string `json:"pull_request_id"`
+}
+
+func (h *Handler) ClosePR(w http.ResponseWriter, r *http.Request) {
+	var req prReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pr, err := h.pr.ClosePR(r.Context(), req.PullRequestID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pull_request": pr,
+	})
+}
+
+func (h *Handler) ReopenPR(w http.ResponseWriter, r *http.Request) {
+	var req prReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pr, err := h.pr.ReopenPR(r.Context(), req.PullRequestID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pull_request": pr,
+	})
+}
+
+type ErrResp struct {
+	Error string `json:"error"`
+}
+
+func writeErr(w http.ResponseWriter, err error) {
+	var status int
+	switch {
+	case errors.Is(err, pg.ErrPRNotFound), errors.Is(err, pg.ErrNotFound),
+		errors.Is(err, pg.ErrNotTeamMember), errors.Is(err, service.ErrUserNotFound),
+		errors.Is(err, service.ErrChatChannelNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, pg.ErrExclusionExists), errors.Is(err, pg.ErrTeamExists),
+		errors.Is(err, pg.ErrAlreadyMember), errors.Is(err, pg.ErrTeamHasOpenPRs),
+		errors.Is(err, pg.ErrTeamHasSubteams), errors.Is(err, pg.ErrTeamCycle),
+		errors.Is(err, pg.ErrNotAssigned):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrUnauthorized):
+		status = http.StatusUnauthorized
+	case errors.Is(err, service.ErrForbidden):
+		status = http.StatusForbidden
+	case service.IsCodeError(err):
+		status = http.StatusBadRequest
+	default:
+		status = http.StatusInternalServerError
+	}
+
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrResp{Error: err.Error()})
+}
+
 func (h *Handler) ReassignReviewer(w http.ResponseWriter, r *http.Request) {
 	type Req struct {
 		PullRequestID string `json:"pull_request_id"`
 		OldReviewerID string `json:"old_reviewer_id"`
+		NewReviewerID string `json:"new_reviewer_id,omitempty"`
 	}
 
 	type OKResp struct {
 		NewReviewerID string `json:"new_reviewer_id"`
 	}
 
-	type ErrResp struct {
-		Error string `json:"error"`
-	}
-
 	var req Req
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	newID, err := h.pr.ReassignReviewer(r.Context(), req.PullRequestID, req.OldReviewerID)
+	newID, err := h.pr.ReassignReviewer(r.Context(), req.PullRequestID, req.OldReviewerID, req.NewReviewerID)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(ErrResp{Error: err.Error()})
+		writeErr(w, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(OKResp{NewReviewerID: newID})
 }
 
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+type reviewerReq struct {
+	PullRequestID string `json:"pull_request_id"`
+	ReviewerID    string `json:"reviewer_id"`
+}
+
+func (h *Handler) AddReviewer(w http.ResponseWriter, r *http.Request) {
+	var req reviewerReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pr, err := h.pr.AddReviewer(r.Context(), req.PullRequestID, req.ReviewerID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pull_request": pr,
+	})
+}
+
+func (h *Handler) RemoveReviewer(w http.ResponseWriter, r *http.Request) {
+	var req reviewerReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pr, err := h.pr.RemoveReviewer(r.Context(), req.PullRequestID, req.ReviewerID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pull_request": pr,
+	})
+}
+
+func (h *Handler) GetPR(w http.ResponseWriter, r *http.Request) {
+	prID := r.URL.Query().Get("pull_request_id")
+	if prID == "" {
+		http.Error(w, "pull_request_id is required", http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.pr.GetPR(r.Context(), prID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	etag := fmt.Sprintf(`"%d"`, d.PullRequest.Version)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	json.NewEncoder(w).Encode(d)
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag.
+func etagMatches(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) SubmitReview(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PullRequestID string                `json:"pull_request_id"`
+		ReviewerID    string                `json:"reviewer_id"`
+		Decision      domain.ReviewDecision `json:"decision"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pr, err := h.pr.SubmitReview(r.Context(), req.PullRequestID, req.ReviewerID, req.Decision)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pull_request": pr,
+		"decision":     req.Decision,
+	})
+}
+
+func (h *Handler) RemindReviewers(w http.ResponseWriter, r *http.Request) {
+	var req prReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reminded, err := h.pr.RemindReviewers(r.Context(), req.PullRequestID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pull_request_id": req.PullRequestID,
+		"reminded":        reminded,
+	})
+}
+
+func (h *Handler) ListSLAActions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit, err := parseIntParam(q.Get("limit"))
+	if err != nil {
+		http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, err := h.sla.Actions(r.Context(), q.Get("pull_request_id"), limit)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"actions": list,
+	})
+}
+
 func (h *Handler) ListReviews(w http.ResponseWriter, r *http.Request) {
-	userID := r.URL.Query().Get("user_id")
+	q := r.URL.Query()
+
+	userID := q.Get("user_id")
 	if userID == "" {
 		http.Error(w, "user_id is required", http.StatusBadRequest)
 		return
 	}
 
-	prs, err := h.pr.ListPRByReviewer(r.Context(), userID)
+	f := domain.PRListFilter{
+		Status: domain.PRStatus(q.Get("status")),
+		Sort:   domain.PRSort(q.Get("sort")),
+	}
+
+	var err error
+	if v := q.Get("pending_my_review"); v != "" {
+		if f.PendingReview, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "invalid pending_my_review: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if f.Limit, err = parseIntParam(q.Get("limit")); err != nil {
+		http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prs, next, err := h.pr.ListReviews(r.Context(), userID, f, q.Get("cursor"))
 	if err != nil {
-		http.Error(w, err.Error(), 500)
+		writeErr(w, err)
 		return
 	}
 
-	resp := map[string]interface{}{
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user_id":       userID,
 		"pull_requests": prs,
+		"next_cursor":   next,
+	})
+}
+
+func (h *Handler) ListPRs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	f := domain.PRListFilter{
+		Status:     domain.PRStatus(q.Get("status")),
+		AuthorID:   q.Get("author_id"),
+		ReviewerID: q.Get("reviewer_id"),
+		TeamName:   q.Get("team_name"),
+		Sort:       domain.PRSort(q.Get("sort")),
 	}
 
-	json.NewEncoder(w).Encode(resp)
+	var err error
+	if f.CreatedFrom, err = parseTimeParam(q.Get("created_from")); err != nil {
+		http.Error(w, "invalid created_from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if f.CreatedTo, err = parseTimeParam(q.Get("created_to")); err != nil {
+		http.Error(w, "invalid created_to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if f.Limit, err = parseIntParam(q.Get("limit")); err != nil {
+		http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prs, next, err := h.pr.ListPRs(r.Context(), f, q.Get("cursor"))
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pull_requests": prs,
+		"next_cursor":   next,
+	})
 }
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+	"github.com/egoisthemain/pr-reviewer/internal/service"
+)
+
+func (h *Handler) ReviewerStats(w http.ResponseWriter, r *http.Request) {
+	f, ok := parseStatsFilter(w, r.URL.Query())
+	if !ok {
+		return
+	}
+
+	stats, err := h.stats.ReviewerStats(r.Context(), f, r.URL.Query().Get("include_subtree") == "true")
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":      f.From,
+		"to":        f.To,
+		"reviewers": stats,
+	})
+}
+
+func (h *Handler) TeamStats(w http.ResponseWriter, r *http.Request) {
+	f, ok := parseStatsFilter(w, r.URL.Query())
+	if !ok {
+		return
+	}
+
+	stats, err := h.stats.TeamStats(r.Context(), f, r.URL.Query().Get("include_subtree") == "true")
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":  f.From,
+		"to":    f.To,
+		"teams": stats,
+	})
+}
+
+func (h *Handler) FairnessReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	f, ok := parseStatsFilter(w, q)
+	if !ok {
+		return
+	}
+
+	maxGini, err := parseFloatParam(q.Get("max_gini"), service.DefaultMaxGini)
+	if err != nil {
+		http.Error(w, "invalid max_gini: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	tolerance, err := parseFloatParam(q.Get("tolerance"), service.DefaultTolerance)
+	if err != nil {
+		http.Error(w, "invalid tolerance: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.stats.Fairness(r.Context(), f, maxGini, tolerance)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	skewed := make([]string, 0)
+	for _, t := range report {
+		if t.Skewed {
+			skewed = append(skewed, t.TeamName)
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"from":         f.From,
+		"to":           f.To,
+		"max_gini":     maxGini,
+		"skewed_teams": skewed,
+		"teams":        report,
+	})
+}
+
+func parseFloatParam(v string, def float64) (float64, error) {
+	if v == "" {
+		return def, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// parseStatsFilter reads from, to and any number of team parameters. It
+// writes the error response itself and reports whether the handler may go on.
+func parseStatsFilter(w http.ResponseWriter, q url.Values) (domain.StatsFilter, bool) {
+	f := domain.StatsFilter{Teams: q["team"]}
+
+	from, err := parseTimeParam(q.Get("from"))
+	if err != nil {
+		http.Error(w, "invalid from: "+err.Error(), http.StatusBadRequest)
+		return f, false
+	}
+	to, err := parseTimeParam(q.Get("to"))
+	if err != nil {
+		http.Error(w, "invalid to: "+err.Error(), http.StatusBadRequest)
+		return f, false
+	}
+
+	if from != nil {
+		f.From = *from
+	}
+	if to != nil {
+		f.To = *to
+	}
+	return service.DefaultStatsWindow(f), true
+}
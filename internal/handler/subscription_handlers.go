@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type subscriptionReq struct {
+	ID       int64                    `json:"id"`
+	URL      string                   `json:"url"`
+	Events   []domain.DomainEventType `json:"events"`
+	TeamName string                   `json:"team_name"`
+	Secret   string                   `json:"secret"`
+	Active   *bool                    `json:"active"`
+}
+
+func (req subscriptionReq) subscription() domain.WebhookSubscription {
+	s := domain.WebhookSubscription{
+		ID:       req.ID,
+		URL:      req.URL,
+		Events:   req.Events,
+		TeamName: req.TeamName,
+		Secret:   req.Secret,
+		Active:   req.Active == nil || *req.Active,
+	}
+	if s.Events == nil {
+		s.Events = []domain.DomainEventType{}
+	}
+	return s
+}
+
+func (h *Handler) CreateSubscription(w http.ResponseWriter, r *http.Request) {
+	var req subscriptionReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub, secret, err := h.webhooks.Create(r.Context(), req.subscription())
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subscription": sub,
+		"secret":       secret,
+	})
+}
+
+func (h *Handler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
+	var req subscriptionReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sub, err := h.webhooks.Update(r.Context(), req.subscription())
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subscription": sub,
+	})
+}
+
+func (h *Handler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		ID int64 `json:"id"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.webhooks.Delete(r.Context(), req.ID); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	list, err := h.webhooks.List(r.Context())
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subscriptions": list,
+	})
+}
+
+func (h *Handler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	id, err := strconv.ParseInt(q.Get("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid id: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	limit, err := parseIntParam(q.Get("limit"))
+	if err != nil {
+		http.Error(w, "invalid limit: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, err := h.webhooks.Deliveries(r.Context(), id, domain.WebhookDeliveryStatus(q.Get("status")), limit)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"subscription_id": id,
+		"deliveries":      list,
+	})
+}
+
+func (h *Handler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		ID         int64 `json:"id"`
+		DeliveryID int64 `json:"delivery_id"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.webhooks.Redeliver(r.Context(), req.ID, req.DeliveryID); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -16,7 +16,327 @@ func (h *Handler) CreateTeam(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.tm.CreateTeam(r.Context(), t); err != nil {
-		http.Error(w, err.Error(), 500)
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"team": t,
+	})
+}
+
+func (h *Handler) SetUserActive(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		UserID   string `json:"user_id"`
+		IsActive bool   `json:"is_active"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := h.tm.SetUserActive(r.Context(), req.UserID, req.IsActive)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user": u,
+	})
+}
+
+func (h *Handler) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		UserID string          `json:"user_id"`
+		Role   domain.UserRole `json:"role"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := h.tm.SetUserRole(r.Context(), req.UserID, req.Role)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user": u,
+	})
+}
+
+func (h *Handler) SetShadowFraction(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		TeamName       string  `json:"team_name"`
+		ShadowFraction float64 `json:"shadow_fraction"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := h.tm.SetShadowFraction(r.Context(), req.TeamName, req.ShadowFraction)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"team": t,
+	})
+}
+
+func (h *Handler) SetReviewSLA(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		TeamName string `json:"team_name"`
+		domain.ReviewSLA
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := h.tm.SetReviewSLA(r.Context(), req.TeamName, req.ReviewSLA)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"team": t,
+	})
+}
+
+func (h *Handler) GetTeam(w http.ResponseWriter, r *http.Request) {
+	teamName := r.URL.Query().Get("team_name")
+	if teamName == "" {
+		http.Error(w, "team_name is required", http.StatusBadRequest)
+		return
+	}
+
+	var t *domain.Team
+	var err error
+	if r.URL.Query().Get("subtree") == "true" {
+		t, err = h.tm.GetTeamTree(r.Context(), teamName)
+	} else {
+		t, err = h.tm.GetTeam(r.Context(), teamName)
+	}
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"team": t,
+	})
+}
+
+func (h *Handler) RenameTeam(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		TeamName    string `json:"team_name"`
+		NewTeamName string `json:"new_team_name"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := h.tm.RenameTeam(r.Context(), req.TeamName, req.NewTeamName)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"team": t,
+	})
+}
+
+func (h *Handler) DeleteTeam(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		TeamName string `json:"team_name"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tm.DeleteTeam(r.Context(), req.TeamName); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) AddTeamMember(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		TeamName string      `json:"team_name"`
+		User     domain.User `json:"user"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := h.tm.AddMember(r.Context(), req.TeamName, req.User)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"team": t,
+	})
+}
+
+func (h *Handler) RemoveTeamMember(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		TeamName string `json:"team_name"`
+		UserID   string `json:"user_id"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := h.tm.RemoveMember(r.Context(), req.TeamName, req.UserID)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"team": t,
+	})
+}
+
+func (h *Handler) MoveUser(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		UserID       string `json:"user_id"`
+		FromTeamName string `json:"from_team_name,omitempty"`
+		TeamName     string `json:"team_name"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := h.tm.MoveUser(r.Context(), req.UserID, req.FromTeamName, req.TeamName)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user": u,
+	})
+}
+
+func (h *Handler) SetUsername(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		UserID   string `json:"user_id"`
+		Username string `json:"username"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := h.tm.SetUsername(r.Context(), req.UserID, req.Username)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user": u,
+	})
+}
+
+func (h *Handler) SetEmail(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		UserID string `json:"user_id"`
+		Email  string `json:"email"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := h.tm.SetEmail(r.Context(), req.UserID, req.Email)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user": u,
+	})
+}
+
+func (h *Handler) SetWorkSchedule(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		UserID string `json:"user_id"`
+		domain.WorkSchedule
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := h.tm.SetWorkSchedule(r.Context(), req.UserID, req.WorkSchedule)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user": u,
+	})
+}
+
+func (h *Handler) SetTeamParent(w http.ResponseWriter, r *http.Request) {
+	type Req struct {
+		TeamName       string `json:"team_name"`
+		ParentTeamName string `json:"parent_team_name"`
+	}
+
+	var req Req
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	t, err := h.tm.SetParent(r.Context(), req.TeamName, req.ParentTeamName)
+	if err != nil {
+		writeErr(w, err)
 		return
 	}
 
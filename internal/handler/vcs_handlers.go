@@ -0,0 +1,255 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+	"github.com/egoisthemain/pr-reviewer/internal/service"
+)
+
+// maxWebhookBody caps webhook payloads; PR events are far smaller.
+const maxWebhookBody = 5 << 20
+
+func (h *Handler) GitHubWebhook(w http.ResponseWriter, r *http.Request) {
+	if h.hooks.GitHubSecret == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !validGitHubSignature(h.hooks.GitHubSecret, body, r.Header.Get("X-Hub-Signature-256")) {
+		writeErr(w, service.ErrUnauthorized)
+		return
+	}
+
+	if r.Header.Get("X-GitHub-Event") != "pull_request" {
+		writeWebhookResult(w, service.VCSIgnored, "")
+		return
+	}
+
+	e, err := parseGitHubEvent(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	e.DeliveryID = r.Header.Get("X-GitHub-Delivery")
+
+	h.handleVCSEvent(w, r, e)
+}
+
+// validGitHubSignature checks the "sha256=<hex hmac>" header GitHub signs
+// payloads with.
+func validGitHubSignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func parseGitHubEvent(body []byte) (domain.VCSEvent, error) {
+	var p struct {
+		Action      string `json:"action"`
+		PullRequest struct {
+			Number int    `json:"number"`
+			Title  string `json:"title"`
+			Merged bool   `json:"merged"`
+			User   struct {
+				Login string `json:"login"`
+			} `json:"user"`
+		} `json:"pull_request"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return domain.VCSEvent{}, err
+	}
+
+	e := domain.VCSEvent{
+		Provider:    domain.ProviderGitHub,
+		Repository:  p.Repository.FullName,
+		Number:      p.PullRequest.Number,
+		Title:       p.PullRequest.Title,
+		AuthorLogin: p.PullRequest.User.Login,
+	}
+
+	switch p.Action {
+	case "opened":
+		e.Action = domain.VCSOpened
+	case "reopened":
+		e.Action = domain.VCSReopened
+	case "closed":
+		e.Action = domain.VCSClosed
+		if p.PullRequest.Merged {
+			e.Action = domain.VCSMerged
+		}
+	}
+	return e, nil
+}
+
+func (h *Handler) GitLabWebhook(w http.ResponseWriter, r *http.Request) {
+	if h.hooks.GitLabToken == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	token := r.Header.Get("X-Gitlab-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.hooks.GitLabToken)) != 1 {
+		writeErr(w, service.ErrUnauthorized)
+		return
+	}
+
+	if r.Header.Get("X-Gitlab-Event") != "Merge Request Hook" {
+		writeWebhookResult(w, service.VCSIgnored, "")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	e, err := parseGitLabEvent(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	e.DeliveryID = r.Header.Get("X-Gitlab-Event-UUID")
+
+	h.handleVCSEvent(w, r, e)
+}
+
+// parseGitLabEvent reads a merge request hook. The author is
+// object_attributes.author_id; the hook only names the user who triggered the
+// event by login, so the login is taken from there when that user is the
+// author and is looked up by ID otherwise.
+func parseGitLabEvent(body []byte) (domain.VCSEvent, error) {
+	var p struct {
+		User struct {
+			ID       int    `json:"id"`
+			Username string `json:"username"`
+		} `json:"user"`
+		Project struct {
+			PathWithNamespace string `json:"path_with_namespace"`
+		} `json:"project"`
+		ObjectAttributes struct {
+			IID      int    `json:"iid"`
+			Title    string `json:"title"`
+			Action   string `json:"action"`
+			AuthorID int    `json:"author_id"`
+		} `json:"object_attributes"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return domain.VCSEvent{}, err
+	}
+
+	e := domain.VCSEvent{
+		Provider:   domain.ProviderGitLab,
+		Repository: p.Project.PathWithNamespace,
+		Number:     p.ObjectAttributes.IID,
+		Title:      p.ObjectAttributes.Title,
+		AuthorID:   p.ObjectAttributes.AuthorID,
+	}
+	if p.User.ID != 0 && p.User.ID == p.ObjectAttributes.AuthorID {
+		e.AuthorLogin = p.User.Username
+	}
+
+	switch p.ObjectAttributes.Action {
+	case "open":
+		e.Action = domain.VCSOpened
+	case "reopen":
+		e.Action = domain.VCSReopened
+	case "merge":
+		e.Action = domain.VCSMerged
+	case "close":
+		e.Action = domain.VCSClosed
+	}
+	return e, nil
+}
+
+func (h *Handler) handleVCSEvent(w http.ResponseWriter, r *http.Request, e domain.VCSEvent) {
+	if e.Action == "" {
+		writeWebhookResult(w, service.VCSIgnored, "")
+		return
+	}
+
+	outcome, prID, err := h.vcs.HandleEvent(r.Context(), e)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	writeWebhookResult(w, outcome, prID)
+}
+
+func writeWebhookResult(w http.ResponseWriter, outcome, prID string) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":          outcome,
+		"pull_request_id": prID,
+	})
+}
+
+func (h *Handler) SetVCSIdentity(w http.ResponseWriter, r *http.Request) {
+	var req domain.VCSIdentity
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.vcs.SetIdentity(r.Context(), req); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"identity": req,
+	})
+}
+
+func (h *Handler) DeleteVCSIdentity(w http.ResponseWriter, r *http.Request) {
+	var req domain.VCSIdentity
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.vcs.DeleteIdentity(r.Context(), req.Provider, req.Login); err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ListVCSIdentities(w http.ResponseWriter, r *http.Request) {
+	list, err := h.vcs.ListIdentities(r.Context(), domain.VCSProvider(r.URL.Query().Get("provider")))
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"identities": list,
+	})
+}
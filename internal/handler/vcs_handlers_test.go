@@ -0,0 +1,516 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+	"github.com/egoisthemain/pr-reviewer/internal/repository/pg"
+	"github.com/egoisthemain/pr-reviewer/internal/service"
+	"github.com/egoisthemain/pr-reviewer/internal/vcs"
+)
+
+const (
+	testGitHubSecret = "s3cret"
+	testGitHubPRID   = "github:acme/api#42"
+	testGitLabToken  = "gl-token"
+	testGitLabPRID   = "gitlab:acme/api#7"
+)
+
+// The in-memory repositories below implement what VCSService and PRService
+// touch for webhook events. The embedded interfaces panic on anything else.
+
+type memVCSRepo struct {
+	identities map[string]string
+	deliveries map[string]bool
+}
+
+func (r *memVCSRepo) SetIdentity(ctx context.Context, id domain.VCSIdentity) error {
+	r.identities[string(id.Provider)+":"+id.Login] = id.UserID
+	return nil
+}
+
+func (r *memVCSRepo) DeleteIdentity(ctx context.Context, provider domain.VCSProvider, login string) error {
+	delete(r.identities, string(provider)+":"+login)
+	return nil
+}
+
+func (r *memVCSRepo) ListIdentities(ctx context.Context, provider domain.VCSProvider) ([]domain.VCSIdentity, error) {
+	return nil, nil
+}
+
+func (r *memVCSRepo) ResolveIdentity(ctx context.Context, provider domain.VCSProvider, login string) (string, error) {
+	return r.identities[string(provider)+":"+login], nil
+}
+
+func (r *memVCSRepo) RecordDelivery(ctx context.Context, provider domain.VCSProvider, deliveryID string) (bool, error) {
+	key := string(provider) + ":" + deliveryID
+	if r.deliveries[key] {
+		return false, nil
+	}
+	r.deliveries[key] = true
+	return true, nil
+}
+
+type memPRRepo struct {
+	service.PullRequestRepo
+	prs map[string]*domain.PullRequest
+}
+
+func (r *memPRRepo) CreatePR(ctx context.Context, pr domain.PullRequest) error {
+	pr.AssignedReviewers = nil
+	r.prs[pr.PullRequestID] = &pr
+	return nil
+}
+
+func (r *memPRRepo) GetPR(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	pr, ok := r.prs[prID]
+	if !ok {
+		return nil, pg.ErrPRNotFound
+	}
+	out := *pr
+	return &out, nil
+}
+
+func (r *memPRRepo) PRExists(ctx context.Context, prID string) (bool, error) {
+	_, ok := r.prs[prID]
+	return ok, nil
+}
+
+func (r *memPRRepo) AddReviewer(ctx context.Context, prID, userID string) error {
+	pr := r.prs[prID]
+	pr.AssignedReviewers = append(pr.AssignedReviewers, userID)
+	return nil
+}
+
+func (r *memPRRepo) AddShadowReviewer(ctx context.Context, prID, userID string) error {
+	pr := r.prs[prID]
+	pr.ShadowReviewers = append(pr.ShadowReviewers, userID)
+	return nil
+}
+
+func (r *memPRRepo) AddEvent(ctx context.Context, prID string, eventType domain.PREventType, userID string) error {
+	return nil
+}
+
+func (r *memPRRepo) SetMerged(ctx context.Context, prID string) error {
+	r.prs[prID].Status = domain.PRMerged
+	return nil
+}
+
+func (r *memPRRepo) SetClosed(ctx context.Context, prID string) error {
+	r.prs[prID].Status = domain.PRClosed
+	return nil
+}
+
+func (r *memPRRepo) SetReopened(ctx context.Context, prID string) error {
+	r.prs[prID].Status = domain.PROpen
+	return nil
+}
+
+type memTeamRepo struct {
+	service.TeamRepo
+	team domain.Team
+}
+
+func (r *memTeamRepo) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	for _, u := range r.team.Members {
+		if u.UserID == userID {
+			u.Teams = []string{r.team.TeamName}
+			return &u, nil
+		}
+	}
+	return nil, pg.ErrNotFound
+}
+
+func (r *memTeamRepo) GetTeam(ctx context.Context, teamName string) (*domain.Team, error) {
+	if teamName != r.team.TeamName {
+		return nil, pg.ErrNotFound
+	}
+	t := r.team
+	return &t, nil
+}
+
+func (r *memTeamRepo) ListAncestors(ctx context.Context, teamName string) ([]string, error) {
+	return nil, nil
+}
+
+type memExclusionRepo struct {
+	service.ExclusionRepo
+}
+
+func (memExclusionRepo) ExcludedReviewers(ctx context.Context, authorID string) ([]string, error) {
+	return nil, nil
+}
+
+type memOutboxRepo struct {
+	service.OutboxRepo
+	events []domain.OutboxEvent
+}
+
+func (r *memOutboxRepo) AddOutboxEvent(ctx context.Context, e domain.OutboxEvent) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+type memUserLookup map[int]string
+
+func (l memUserLookup) Login(ctx context.Context, userID int) (string, error) {
+	return l[userID], nil
+}
+
+type noTx struct{}
+
+func (noTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// deliveryTx rolls the recorded deliveries back when fn fails.
+type deliveryTx struct{ repo *memVCSRepo }
+
+func (tx deliveryTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	saved := make(map[string]bool, len(tx.repo.deliveries))
+	for k, v := range tx.repo.deliveries {
+		saved[k] = v
+	}
+	if err := fn(ctx); err != nil {
+		tx.repo.deliveries = saved
+		return err
+	}
+	return nil
+}
+
+type webhookEnv struct {
+	h   *Handler
+	vcs *memVCSRepo
+	prs *memPRRepo
+}
+
+// newWebhookEnv serves the webhooks for team backend, where octo-alice on
+// GitHub and alice (ID 1001) on GitLab are mapped to u1 and u2 is the only
+// possible reviewer.
+func newWebhookEnv(t *testing.T) *webhookEnv {
+	t.Helper()
+	vcsRepo := &memVCSRepo{
+		identities: map[string]string{"github:octo-alice": "u1", "gitlab:alice": "u1"},
+		deliveries: map[string]bool{},
+	}
+	prRepo := &memPRRepo{prs: map[string]*domain.PullRequest{}}
+	teamRepo := &memTeamRepo{team: domain.Team{
+		TeamName: "backend",
+		Members: []domain.User{
+			{UserID: "u1", Username: "Alice", IsActive: true},
+			{UserID: "u2", Username: "Bob", IsActive: true},
+		},
+	}}
+
+	prs := service.NewPRService(prRepo, teamRepo, memExclusionRepo{}, nil, nil, noTx{}, &memOutboxRepo{})
+	users := map[domain.VCSProvider]vcs.UserLookup{
+		domain.ProviderGitLab: memUserLookup{1001: "alice", 1002: "bob"},
+	}
+	vcsService := service.NewVCSService(vcsRepo, prs, nil, deliveryTx{vcsRepo}, users)
+	return &webhookEnv{
+		h: &Handler{vcs: vcsService, hooks: WebhookConfig{
+			GitHubSecret: testGitHubSecret,
+			GitLabToken:  testGitLabToken,
+		}},
+		vcs: vcsRepo,
+		prs: prRepo,
+	}
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func githubSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (env *webhookEnv) deliver(t *testing.T, fixture, deliveryID, signature string) (int, map[string]string) {
+	t.Helper()
+	body := readFixture(t, fixture)
+	if signature == "" {
+		signature = githubSignature(testGitHubSecret, body)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/github", bytes.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "pull_request")
+	req.Header.Set("X-GitHub-Delivery", deliveryID)
+	req.Header.Set("X-Hub-Signature-256", signature)
+	rec := httptest.NewRecorder()
+	env.h.GitHubWebhook(rec, req)
+
+	var resp map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, resp
+}
+
+func (env *webhookEnv) deliverGitLab(t *testing.T, body []byte, deliveryID string) (int, map[string]string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/gitlab", bytes.NewReader(body))
+	req.Header.Set("X-Gitlab-Event", "Merge Request Hook")
+	req.Header.Set("X-Gitlab-Event-UUID", deliveryID)
+	req.Header.Set("X-Gitlab-Token", testGitLabToken)
+	rec := httptest.NewRecorder()
+	env.h.GitLabWebhook(rec, req)
+
+	var resp map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, resp
+}
+
+// triggeredBy rewrites the user of a GitLab hook.
+func triggeredBy(t *testing.T, body []byte, id int, username string) []byte {
+	t.Helper()
+	var p map[string]any
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatal(err)
+	}
+	p["user"] = map[string]any{"id": id, "username": username}
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParseGitHubEvent(t *testing.T) {
+	tests := []struct {
+		fixture string
+		action  domain.VCSAction
+	}{
+		{"github_pull_request_opened.json", domain.VCSOpened},
+		{"github_pull_request_closed.json", domain.VCSClosed},
+		{"github_pull_request_merged.json", domain.VCSMerged},
+		{"github_pull_request_reopened.json", domain.VCSReopened},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			e, err := parseGitHubEvent(readFixture(t, tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := domain.VCSEvent{
+				Provider:    domain.ProviderGitHub,
+				Action:      tt.action,
+				Repository:  "acme/api",
+				Number:      42,
+				Title:       "Add rate limiting to the public API",
+				AuthorLogin: "octo-alice",
+			}
+			if e != want {
+				t.Fatalf("parseGitHubEvent() = %+v; want %+v", e, want)
+			}
+		})
+	}
+}
+
+func TestGitHubWebhook(t *testing.T) {
+	t.Run("good signature", func(t *testing.T) {
+		env := newWebhookEnv(t)
+		code, resp := env.deliver(t, "github_pull_request_opened.json", "d-1", "")
+		if code != http.StatusOK || resp["status"] != service.VCSProcessed || resp["pull_request_id"] != testGitHubPRID {
+			t.Fatalf("opened = %d %v; want 200 processed %s", code, resp, testGitHubPRID)
+		}
+
+		pr := env.prs.prs[testGitHubPRID]
+		if pr == nil || pr.AuthorID != "u1" || pr.Status != domain.PROpen {
+			t.Fatalf("stored PR = %+v; want open PR by u1", pr)
+		}
+		if len(pr.AssignedReviewers) != 1 || pr.AssignedReviewers[0] != "u2" {
+			t.Fatalf("reviewers = %v; want [u2]", pr.AssignedReviewers)
+		}
+	})
+
+	t.Run("bad signature", func(t *testing.T) {
+		env := newWebhookEnv(t)
+		body := readFixture(t, "github_pull_request_opened.json")
+		for _, sig := range []string{
+			githubSignature("wrong", body),
+			strings.TrimPrefix(githubSignature(testGitHubSecret, body), "sha256="),
+			"sha256=zz",
+		} {
+			code, _ := env.deliver(t, "github_pull_request_opened.json", "d-1", sig)
+			if code != http.StatusUnauthorized {
+				t.Fatalf("signature %q: status %d; want 401", sig, code)
+			}
+		}
+		if len(env.prs.prs) != 0 || len(env.vcs.deliveries) != 0 {
+			t.Fatalf("rejected deliveries left state: prs %v, deliveries %v", env.prs.prs, env.vcs.deliveries)
+		}
+	})
+
+	t.Run("unmapped login", func(t *testing.T) {
+		env := newWebhookEnv(t)
+		delete(env.vcs.identities, "github:octo-alice")
+
+		code, resp := env.deliver(t, "github_pull_request_opened.json", "d-1", "")
+		if code != http.StatusBadRequest || !strings.Contains(resp["error"], "UNKNOWN_IDENTITY") {
+			t.Fatalf("opened = %d %v; want 400 UNKNOWN_IDENTITY", code, resp)
+		}
+		if len(env.prs.prs) != 0 {
+			t.Fatalf("PR created for an unmapped author: %v", env.prs.prs)
+		}
+
+		// The failed delivery is rolled back with its transaction, so
+		// GitHub's redelivery succeeds once the login is mapped.
+		env.vcs.identities["github:octo-alice"] = "u1"
+		code, resp = env.deliver(t, "github_pull_request_opened.json", "d-1", "")
+		if code != http.StatusOK || resp["status"] != service.VCSProcessed {
+			t.Fatalf("redelivery = %d %v; want 200 processed", code, resp)
+		}
+	})
+
+	t.Run("duplicate delivery id", func(t *testing.T) {
+		env := newWebhookEnv(t)
+		if code, resp := env.deliver(t, "github_pull_request_opened.json", "d-1", ""); resp["status"] != service.VCSProcessed {
+			t.Fatalf("first delivery = %d %v; want processed", code, resp)
+		}
+		if code, resp := env.deliver(t, "github_pull_request_closed.json", "d-1", ""); resp["status"] != service.VCSDuplicate {
+			t.Fatalf("repeated delivery = %d %v; want duplicate", code, resp)
+		}
+		if got := env.prs.prs[testGitHubPRID].Status; got != domain.PROpen {
+			t.Fatalf("status after duplicate = %s; want OPEN", got)
+		}
+	})
+
+	t.Run("other event", func(t *testing.T) {
+		env := newWebhookEnv(t)
+		body := []byte(`{"zen":"Keep it logically awesome."}`)
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/github", bytes.NewReader(body))
+		req.Header.Set("X-GitHub-Event", "ping")
+		req.Header.Set("X-Hub-Signature-256", githubSignature(testGitHubSecret, body))
+		rec := httptest.NewRecorder()
+		env.h.GitHubWebhook(rec, req)
+
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), service.VCSIgnored) {
+			t.Fatalf("ping = %d %s; want 200 ignored", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestGitHubWebhookLifecycle(t *testing.T) {
+	env := newWebhookEnv(t)
+	steps := []struct {
+		fixture string
+		status  domain.PRStatus
+	}{
+		{"github_pull_request_opened.json", domain.PROpen},
+		{"github_pull_request_closed.json", domain.PRClosed},
+		{"github_pull_request_reopened.json", domain.PROpen},
+		{"github_pull_request_merged.json", domain.PRMerged},
+	}
+	for i, s := range steps {
+		code, resp := env.deliver(t, s.fixture, "d-"+s.fixture, "")
+		if code != http.StatusOK || resp["status"] != service.VCSProcessed {
+			t.Fatalf("step %d %s = %d %v; want 200 processed", i, s.fixture, code, resp)
+		}
+		if got := env.prs.prs[testGitHubPRID].Status; got != s.status {
+			t.Fatalf("step %d %s: status %s; want %s", i, s.fixture, got, s.status)
+		}
+	}
+}
+
+func TestGitHubWebhookReopenUnknownPR(t *testing.T) {
+	env := newWebhookEnv(t)
+	for _, fixture := range []string{"github_pull_request_reopened.json", "github_pull_request_closed.json"} {
+		code, resp := env.deliver(t, fixture, "d-"+fixture, "")
+		if code != http.StatusOK || resp["status"] != service.VCSIgnored {
+			t.Fatalf("%s = %d %v; want 200 ignored", fixture, code, resp)
+		}
+	}
+	if len(env.prs.prs) != 0 {
+		t.Fatalf("PR created by a reopen or close: %v", env.prs.prs)
+	}
+}
+
+func TestParseGitLabEvent(t *testing.T) {
+	e, err := parseGitLabEvent(readFixture(t, "gitlab_merge_request_open.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := domain.VCSEvent{
+		Provider:    domain.ProviderGitLab,
+		Action:      domain.VCSOpened,
+		Repository:  "acme/api",
+		Number:      7,
+		Title:       "Cache tariff lookups",
+		AuthorLogin: "alice",
+		AuthorID:    1001,
+	}
+	if e != want {
+		t.Fatalf("open = %+v; want %+v", e, want)
+	}
+
+	// bob reopens alice's MR: bob must not be taken for the author.
+	e, err = parseGitLabEvent(readFixture(t, "gitlab_merge_request_reopen.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Action != domain.VCSReopened || e.AuthorLogin != "" || e.AuthorID != 1001 {
+		t.Fatalf("reopen = %+v; want reopened, author ID 1001, no login", e)
+	}
+}
+
+func TestGitLabWebhook(t *testing.T) {
+	t.Run("reopen of unknown MR", func(t *testing.T) {
+		env := newWebhookEnv(t)
+		code, resp := env.deliverGitLab(t, readFixture(t, "gitlab_merge_request_reopen.json"), "g-1")
+		if code != http.StatusOK || resp["status"] != service.VCSIgnored {
+			t.Fatalf("reopen = %d %v; want 200 ignored", code, resp)
+		}
+		if len(env.prs.prs) != 0 {
+			t.Fatalf("PR created by a reopen: %v", env.prs.prs)
+		}
+	})
+
+	t.Run("opened by another user", func(t *testing.T) {
+		env := newWebhookEnv(t)
+		body := triggeredBy(t, readFixture(t, "gitlab_merge_request_open.json"), 1002, "bob")
+		code, resp := env.deliverGitLab(t, body, "g-1")
+		if code != http.StatusOK || resp["status"] != service.VCSProcessed || resp["pull_request_id"] != testGitLabPRID {
+			t.Fatalf("open = %d %v; want 200 processed %s", code, resp, testGitLabPRID)
+		}
+		if pr := env.prs.prs[testGitLabPRID]; pr.AuthorID != "u1" {
+			t.Fatalf("author = %s; want u1", pr.AuthorID)
+		}
+	})
+
+	t.Run("reopen", func(t *testing.T) {
+		env := newWebhookEnv(t)
+		if _, resp := env.deliverGitLab(t, readFixture(t, "gitlab_merge_request_open.json"), "g-1"); resp["status"] != service.VCSProcessed {
+			t.Fatalf("open = %v; want processed", resp)
+		}
+		env.prs.prs[testGitLabPRID].Status = domain.PRClosed
+
+		code, resp := env.deliverGitLab(t, readFixture(t, "gitlab_merge_request_reopen.json"), "g-2")
+		if code != http.StatusOK || resp["status"] != service.VCSProcessed {
+			t.Fatalf("reopen = %d %v; want 200 processed", code, resp)
+		}
+		if pr := env.prs.prs[testGitLabPRID]; pr.Status != domain.PROpen || pr.AuthorID != "u1" {
+			t.Fatalf("PR after reopen = %+v; want open PR by u1", pr)
+		}
+	})
+}
@@ -0,0 +1,189 @@
+// Package mailer sends multipart emails over SMTP.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is an email with a plain text and an HTML body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Config describes the SMTP server. Without Username no authentication is
+// attempted; STARTTLS is used whenever the server offers it.
+type Config struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	Timeout  time.Duration
+}
+
+type Client struct {
+	cfg  Config
+	host string
+}
+
+func NewClient(cfg Config) (*Client, error) {
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("smtp addr: %w", err)
+	}
+	if cfg.From == "" {
+		return nil, errors.New("smtp: from address is required")
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Minute
+	}
+	return &Client{cfg: cfg, host: host}, nil
+}
+
+// SendBatch delivers msgs over a single connection. The result holds one
+// error per message; if the connection itself fails every message gets that
+// error.
+func (c *Client) SendBatch(ctx context.Context, msgs []Message) []error {
+	errs := make([]error, len(msgs))
+	if len(msgs) == 0 {
+		return errs
+	}
+
+	cl, err := c.dial(ctx)
+	if err != nil {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	defer cl.Close()
+
+	for i, m := range msgs {
+		if errs[i] = c.send(cl, m); errs[i] != nil {
+			// A 4xx/5xx reply leaves the session usable once it is reset;
+			// anything else means the connection is gone.
+			var tpErr *textproto.Error
+			if !errors.As(errs[i], &tpErr) || cl.Reset() != nil {
+				for j := i + 1; j < len(msgs); j++ {
+					errs[j] = errs[i]
+				}
+				return errs
+			}
+		}
+	}
+	cl.Quit()
+	return errs
+}
+
+func (c *Client) dial(ctx context.Context) (*smtp.Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("smtp dial: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(c.cfg.Timeout))
+
+	cl, err := smtp.NewClient(conn, c.host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp greeting: %w", err)
+	}
+	if ok, _ := cl.Extension("STARTTLS"); ok {
+		if err := cl.StartTLS(&tls.Config{ServerName: c.host}); err != nil {
+			cl.Close()
+			return nil, fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if c.cfg.Username != "" {
+		if err := cl.Auth(smtp.PlainAuth("", c.cfg.Username, c.cfg.Password, c.host)); err != nil {
+			cl.Close()
+			return nil, fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	return cl, nil
+}
+
+func (c *Client) send(cl *smtp.Client, m Message) error {
+	body, err := c.compose(m)
+	if err != nil {
+		return err
+	}
+
+	if err := cl.Mail(c.cfg.From); err != nil {
+		return err
+	}
+	if err := cl.Rcpt(m.To); err != nil {
+		return err
+	}
+	w, err := cl.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// compose builds a multipart/alternative message with the text part first,
+// so that clients prefer the HTML one.
+func (c *Client) compose(m Message) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qw := quotedprintable.NewWriter(pw)
+		if _, err := qw.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&msg, "%s: %s\r\n", k, v) }
+	header("From", c.cfg.From)
+	header("To", m.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+hex.EncodeToString(id)+"@"+strings.TrimSuffix(c.host, ".")+">")
+	header("MIME-Version", "1.0")
+	header("Content-Type", "multipart/alternative; boundary="+mw.Boundary())
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
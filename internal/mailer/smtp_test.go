@@ -0,0 +1,262 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type received struct {
+	From string
+	To   []string
+	Data string
+}
+
+// smtpServer is a minimal SMTP server: no TLS, no auth, and recipients
+// starting with "reject" are refused with 550.
+type smtpServer struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	sessions int
+	commands []string
+	messages []received
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{ln: ln}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(func() {
+		ln.Close()
+		s.wg.Wait()
+	})
+	return s
+}
+
+func (s *smtpServer) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer c.Close()
+			s.session(textproto.NewConn(c))
+		}()
+	}
+}
+
+func (s *smtpServer) session(tc *textproto.Conn) {
+	s.mu.Lock()
+	s.sessions++
+	s.mu.Unlock()
+
+	var cur received
+	tc.PrintfLine("220 localhost ESMTP test")
+	for {
+		line, err := tc.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		verb = strings.ToUpper(verb)
+		s.mu.Lock()
+		s.commands = append(s.commands, verb)
+		s.mu.Unlock()
+
+		switch verb {
+		case "EHLO", "HELO":
+			tc.PrintfLine("250-localhost\r\n250 8BITMIME")
+		case "MAIL":
+			from, _, _ := strings.Cut(strings.TrimPrefix(arg, "FROM:"), " ")
+			cur = received{From: strings.Trim(from, "<>")}
+			tc.PrintfLine("250 OK")
+		case "RCPT":
+			to := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			if strings.HasPrefix(to, "reject") {
+				tc.PrintfLine("550 No such user")
+				continue
+			}
+			cur.To = append(cur.To, to)
+			tc.PrintfLine("250 OK")
+		case "DATA":
+			tc.PrintfLine("354 Go ahead")
+			data, err := io.ReadAll(tc.DotReader())
+			if err != nil {
+				return
+			}
+			cur.Data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, cur)
+			s.mu.Unlock()
+			tc.PrintfLine("250 Queued")
+		case "RSET":
+			cur = received{}
+			tc.PrintfLine("250 OK")
+		case "QUIT":
+			tc.PrintfLine("221 Bye")
+			return
+		default:
+			tc.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) snapshot() (int, []string, []received) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions, append([]string(nil), s.commands...), append([]received(nil), s.messages...)
+}
+
+func newTestClient(t *testing.T, addr string) *Client {
+	t.Helper()
+	c, err := NewClient(Config{Addr: addr, From: "reviews@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestSendBatch(t *testing.T) {
+	srv := newSMTPServer(t)
+	c := newTestClient(t, srv.ln.Addr().String())
+
+	msgs := []Message{
+		{
+			To:      "alice@example.com",
+			Subject: "Ревью: Add cache",
+			Text:    "Hi Alice,\n.leading dot survives\nlimit=50 " + strings.Repeat("long ", 30),
+			HTML:    `<p>Hi <b>Alice</b>, <a href="https://example.com/pr?id=1">open</a></p>`,
+		},
+		{To: "reject@example.com", Subject: "Lost", Text: "lost", HTML: "<p>lost</p>"},
+		{To: "bob@example.com", Subject: "Reminder", Text: "Hi Bob", HTML: "<p>Hi Bob</p>"},
+	}
+	errs := c.SendBatch(context.Background(), msgs)
+
+	var tpErr *textproto.Error
+	if errs[0] != nil || errs[2] != nil || !errors.As(errs[1], &tpErr) || tpErr.Code != 550 {
+		t.Fatalf("SendBatch() = %v; want only the rejected recipient to fail with 550", errs)
+	}
+
+	sessions, commands, got := srv.snapshot()
+	if sessions != 1 {
+		t.Fatalf("sessions = %d; want the batch over one connection", sessions)
+	}
+	if commands[len(commands)-1] != "QUIT" {
+		t.Fatalf("commands = %v; want the session to end with QUIT", commands)
+	}
+	if len(got) != 2 || got[0].To[0] != "alice@example.com" || got[1].To[0] != "bob@example.com" {
+		t.Fatalf("delivered %+v; want alice and bob", got)
+	}
+	if got[0].From != "reviews@example.com" {
+		t.Fatalf("MAIL FROM = %q", got[0].From)
+	}
+
+	checkMultipart(t, got[0].Data, msgs[0])
+	checkMultipart(t, got[1].Data, msgs[2])
+}
+
+// checkMultipart parses data as a multipart/alternative email and compares
+// its headers and both bodies with m.
+func checkMultipart(t *testing.T, data string, m Message) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != m.Subject {
+		t.Errorf("Subject = %q, %v; want %q", subject, err, m.Subject)
+	}
+	if to := msg.Header.Get("To"); to != m.To {
+		t.Errorf("To = %q; want %q", to, m.To)
+	}
+	if msg.Header.Get("Message-ID") == "" || msg.Header.Get("Date") == "" {
+		t.Error("Message-ID or Date is missing")
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q; want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+
+	// multipart.Reader undoes the quoted-printable encoding.
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", m.Text},
+		{"text/html; charset=utf-8", m.HTML},
+	} {
+		p, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("part %s: %v", want.contentType, err)
+		}
+		if ct := p.Header.Get("Content-Type"); ct != want.contentType {
+			t.Errorf("part Content-Type = %q; want %q", ct, want.contentType)
+		}
+		body, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != want.body {
+			t.Errorf("%s body = %q; want %q", want.contentType, body, want.body)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("extra part after HTML: %v", err)
+	}
+}
+
+func TestSendBatchQuotedPrintableLines(t *testing.T) {
+	srv := newSMTPServer(t)
+	c := newTestClient(t, srv.ln.Addr().String())
+
+	text := strings.Repeat("a", 200)
+	if errs := c.SendBatch(context.Background(), []Message{{To: "alice@example.com", Text: text, HTML: text}}); errs[0] != nil {
+		t.Fatal(errs[0])
+	}
+
+	_, _, got := srv.snapshot()
+	_, body, _ := strings.Cut(got[0].Data, "\r\n\r\n")
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		if len(sc.Text()) > 78 {
+			t.Fatalf("line of %d characters; quoted-printable keeps lines short", len(sc.Text()))
+		}
+	}
+}
+
+func TestSendBatchDialError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	errs := newTestClient(t, addr).SendBatch(context.Background(), []Message{
+		{To: "alice@example.com"}, {To: "bob@example.com"},
+	})
+	if errs[0] == nil || errs[1] != errs[0] {
+		t.Fatalf("SendBatch() = %v; want the dial error for every message", errs)
+	}
+}
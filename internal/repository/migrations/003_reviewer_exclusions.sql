@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS reviewer_exclusions (
+    id          BIGSERIAL PRIMARY KEY,
+    author_id   TEXT REFERENCES users(user_id) ON DELETE CASCADE,
+    team_name   TEXT REFERENCES teams(team_name) ON DELETE CASCADE,
+    reviewer_id TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    reason      TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK ((author_id IS NULL) <> (team_name IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS reviewer_exclusions_author_uq
+    ON reviewer_exclusions (author_id, reviewer_id) WHERE author_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS reviewer_exclusions_team_uq
+    ON reviewer_exclusions (team_name, reviewer_id) WHERE team_name IS NOT NULL;
@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'MEMBER';
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS shadow_fraction DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE pr_reviewers ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'REVIEWER';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'users_role_check') THEN
+        ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('MEMBER', 'SHADOW'));
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'teams_shadow_fraction_check') THEN
+        ALTER TABLE teams ADD CONSTRAINT teams_shadow_fraction_check CHECK (shadow_fraction BETWEEN 0 AND 1);
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'pr_reviewers_role_check') THEN
+        ALTER TABLE pr_reviewers ADD CONSTRAINT pr_reviewers_role_check CHECK (role IN ('REVIEWER', 'SHADOW'));
+    END IF;
+END $$;
@@ -0,0 +1,36 @@
+-- Пользователь может временно не состоять ни в одной команде
+-- (после удаления из команды или удаления самой команды).
+-- После 006 колонки users.team_name уже нет.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'users' AND column_name = 'team_name'
+    ) THEN
+        ALTER TABLE users ALTER COLUMN team_name DROP NOT NULL;
+    END IF;
+END $$;
+
+-- Переименование команды каскадно обновляет ссылки на неё.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'users_team_name_fkey' AND confupdtype <> 'c'
+    ) THEN
+        ALTER TABLE users DROP CONSTRAINT users_team_name_fkey;
+        ALTER TABLE users ADD CONSTRAINT users_team_name_fkey
+            FOREIGN KEY (team_name) REFERENCES teams(team_name)
+            ON UPDATE CASCADE ON DELETE RESTRICT;
+    END IF;
+
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'reviewer_exclusions_team_name_fkey' AND confupdtype <> 'c'
+    ) THEN
+        ALTER TABLE reviewer_exclusions DROP CONSTRAINT reviewer_exclusions_team_name_fkey;
+        ALTER TABLE reviewer_exclusions ADD CONSTRAINT reviewer_exclusions_team_name_fkey
+            FOREIGN KEY (team_name) REFERENCES teams(team_name)
+            ON UPDATE CASCADE ON DELETE CASCADE;
+    END IF;
+END $$;
@@ -0,0 +1,23 @@
+-- Пользователь может состоять в нескольких командах: вместо users.team_name
+-- используется таблица членства.
+CREATE TABLE IF NOT EXISTS team_members (
+    team_name TEXT NOT NULL REFERENCES teams(team_name) ON UPDATE CASCADE ON DELETE CASCADE,
+    user_id   TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    PRIMARY KEY (team_name, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS team_members_user_idx ON team_members (user_id);
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'users' AND column_name = 'team_name'
+    ) THEN
+        INSERT INTO team_members (team_name, user_id)
+        SELECT team_name, user_id FROM users WHERE team_name IS NOT NULL
+        ON CONFLICT DO NOTHING;
+
+        ALTER TABLE users DROP COLUMN team_name;
+    END IF;
+END $$;
@@ -0,0 +1,13 @@
+-- Команды объединяются в дерево (отдел → команды).
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS parent_team_name TEXT;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'teams_parent_team_name_fkey') THEN
+        ALTER TABLE teams ADD CONSTRAINT teams_parent_team_name_fkey
+            FOREIGN KEY (parent_team_name) REFERENCES teams(team_name)
+            ON UPDATE CASCADE ON DELETE RESTRICT;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS teams_parent_idx ON teams (parent_team_name);
@@ -0,0 +1,46 @@
+-- Время назначения ревьювера нужно для статистики (назначение → merge).
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'pr_reviewers' AND column_name = 'assigned_at'
+    ) THEN
+        ALTER TABLE pr_reviewers ADD COLUMN assigned_at TIMESTAMPTZ;
+
+        UPDATE pr_reviewers r
+        SET assigned_at = pr.created_at
+        FROM pull_requests pr
+        WHERE pr.pull_request_id = r.pull_request_id;
+
+        ALTER TABLE pr_reviewers ALTER COLUMN assigned_at SET DEFAULT now();
+        ALTER TABLE pr_reviewers ALTER COLUMN assigned_at SET NOT NULL;
+    END IF;
+END $$;
+
+-- История PR: назначения, переназначения, снятия ревьюверов и merge.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_tables WHERE tablename = 'pr_events') THEN
+        CREATE TABLE pr_events (
+            id              BIGSERIAL PRIMARY KEY,
+            pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+            event_type      TEXT NOT NULL,
+            user_id         TEXT REFERENCES users(user_id) ON DELETE SET NULL,
+            created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+        );
+
+        -- Существующие назначения переносятся как события ASSIGNED.
+        INSERT INTO pr_events (pull_request_id, event_type, user_id, created_at)
+        SELECT r.pull_request_id, 'ASSIGNED', r.user_id, r.assigned_at
+        FROM pr_reviewers r
+        WHERE r.role = 'REVIEWER';
+
+        INSERT INTO pr_events (pull_request_id, event_type, created_at)
+        SELECT pull_request_id, 'MERGED', merged_at
+        FROM pull_requests
+        WHERE status = 'MERGED' AND merged_at IS NOT NULL;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS pr_events_user_idx ON pr_events (user_id, created_at);
+CREATE INDEX IF NOT EXISTS pr_events_pr_idx ON pr_events (pull_request_id, created_at);
@@ -0,0 +1,4 @@
+-- Индексы для агрегаций по периодам в /stats/*.
+CREATE INDEX IF NOT EXISTS pull_requests_author_created_idx ON pull_requests (author_id, created_at);
+CREATE INDEX IF NOT EXISTS pull_requests_merged_at_idx ON pull_requests (merged_at) WHERE merged_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS pr_events_type_created_idx ON pr_events (event_type, created_at);
@@ -0,0 +1,4 @@
+-- Индексы для /pullRequest/list (keyset-пагинация по created_at, pull_request_id).
+CREATE INDEX IF NOT EXISTS pull_requests_created_idx ON pull_requests (created_at, pull_request_id);
+CREATE INDEX IF NOT EXISTS pull_requests_status_created_idx ON pull_requests (status, created_at, pull_request_id);
+CREATE INDEX IF NOT EXISTS pr_reviewers_user_idx ON pr_reviewers (user_id, pull_request_id);
@@ -0,0 +1,14 @@
+-- Решение ревьювера по PR; NULL — ревью ещё не отправлено.
+ALTER TABLE pr_reviewers ADD COLUMN IF NOT EXISTS decision TEXT;
+ALTER TABLE pr_reviewers ADD COLUMN IF NOT EXISTS decided_at TIMESTAMPTZ;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'pr_reviewers_decision_check') THEN
+        ALTER TABLE pr_reviewers ADD CONSTRAINT pr_reviewers_decision_check
+            CHECK (decision IN ('APPROVED', 'CHANGES_REQUESTED'));
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS pr_reviewers_pending_idx ON pr_reviewers (user_id, pull_request_id)
+    WHERE decision IS NULL;
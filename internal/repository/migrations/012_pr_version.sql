@@ -0,0 +1,28 @@
+-- Версия PR для ETag. Растёт при любом изменении PR или его ревьюверов.
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION pull_requests_bump_version() RETURNS trigger AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION pr_reviewers_bump_version() RETURNS trigger AS $$
+BEGIN
+    UPDATE pull_requests
+    SET version = version
+    WHERE pull_request_id = COALESCE(NEW.pull_request_id, OLD.pull_request_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS pull_requests_version ON pull_requests;
+CREATE TRIGGER pull_requests_version
+    BEFORE UPDATE ON pull_requests
+    FOR EACH ROW EXECUTE FUNCTION pull_requests_bump_version();
+
+DROP TRIGGER IF EXISTS pr_reviewers_version ON pr_reviewers;
+CREATE TRIGGER pr_reviewers_version
+    AFTER INSERT OR UPDATE OR DELETE ON pr_reviewers
+    FOR EACH ROW EXECUTE FUNCTION pr_reviewers_bump_version();
@@ -0,0 +1,11 @@
+-- API-токены. Хранится только SHA-256 токена; сам токен выдаётся один раз.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id          BIGSERIAL PRIMARY KEY,
+    name        TEXT NOT NULL,
+    role        TEXT NOT NULL CHECK (role IN ('admin', 'service', 'user')),
+    user_id     TEXT REFERENCES users(user_id) ON DELETE CASCADE,
+    token_hash  TEXT NOT NULL UNIQUE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    revoked_at  TIMESTAMPTZ,
+    CHECK (role <> 'user' OR user_id IS NOT NULL)
+);
@@ -0,0 +1,4 @@
+-- PR, закрытые без merge (например, в GitHub/GitLab).
+ALTER TYPE pr_status ADD VALUE IF NOT EXISTS 'CLOSED';
+
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS closed_at TIMESTAMPTZ;
@@ -0,0 +1,15 @@
+-- Сопоставление логинов GitHub/GitLab с пользователями сервиса.
+CREATE TABLE IF NOT EXISTS vcs_identities (
+    provider TEXT NOT NULL,
+    login    TEXT NOT NULL,
+    user_id  TEXT NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+    PRIMARY KEY (provider, login)
+);
+
+-- Уже обработанные доставки вебхуков, чтобы повторы не применялись дважды.
+CREATE TABLE IF NOT EXISTS vcs_deliveries (
+    provider    TEXT NOT NULL,
+    delivery_id TEXT NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (provider, delivery_id)
+);
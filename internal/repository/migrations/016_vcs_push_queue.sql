@@ -0,0 +1,13 @@
+-- Неудавшиеся отправки ревьюверов в GitHub/GitLab. Одна строка на PR:
+-- при повторе отправляется текущий состав ревьюверов.
+CREATE TABLE IF NOT EXISTS vcs_push_queue (
+    pull_request_id TEXT PRIMARY KEY REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    failed_at       TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS vcs_push_queue_due_idx ON vcs_push_queue (next_attempt_at)
+    WHERE failed_at IS NULL;
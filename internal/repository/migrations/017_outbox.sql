@@ -0,0 +1,19 @@
+-- Outbox доменных событий. Событие пишется в той же транзакции, что и изменение,
+-- и затем доставляется диспетчером. aggregate_id — PR или пользователь; события
+-- одного агрегата доставляются строго по порядку id.
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id              BIGSERIAL PRIMARY KEY,
+    event_type      TEXT NOT NULL,
+    aggregate_id    TEXT NOT NULL,
+    payload         JSONB NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'DELIVERED', 'DEAD')),
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_events_pending_idx ON outbox_events (aggregate_id, id)
+    WHERE status = 'PENDING';
+CREATE INDEX IF NOT EXISTS outbox_events_status_idx ON outbox_events (status, id);
@@ -0,0 +1,9 @@
+-- Канал команды в чате (Slack-совместимый incoming webhook) и шаблоны сообщений.
+-- Пустой шаблон — шаблон по умолчанию.
+CREATE TABLE IF NOT EXISTS team_chat_channels (
+    team_name            TEXT PRIMARY KEY REFERENCES teams(team_name) ON UPDATE CASCADE ON DELETE CASCADE,
+    webhook_url          TEXT NOT NULL,
+    assigned_template    TEXT NOT NULL DEFAULT '',
+    deactivated_template TEXT NOT NULL DEFAULT '',
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,20 @@
+-- SLA ревью команды (по командам автора PR): через сколько минут без решения ревьюверу
+-- напоминают и через сколько его переназначают. 0 — выключено.
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS sla_reminder_minutes INT NOT NULL DEFAULT 0;
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS sla_reassign_minutes INT NOT NULL DEFAULT 0;
+
+-- Действия планировщика SLA. assigned_at отличает одно назначение ревьювера от другого,
+-- поэтому на каждое назначение приходится не больше одного напоминания и одного переназначения.
+CREATE TABLE IF NOT EXISTS sla_actions (
+    id              BIGSERIAL PRIMARY KEY,
+    pull_request_id TEXT NOT NULL REFERENCES pull_requests(pull_request_id) ON DELETE CASCADE,
+    user_id         TEXT NOT NULL,
+    action          TEXT NOT NULL CHECK (action IN ('REMINDER', 'REASSIGN')),
+    assigned_at     TIMESTAMPTZ NOT NULL,
+    new_user_id     TEXT NOT NULL DEFAULT '',
+    error           TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (pull_request_id, user_id, assigned_at, action)
+);
+
+CREATE INDEX IF NOT EXISTS sla_actions_created_idx ON sla_actions (created_at DESC);
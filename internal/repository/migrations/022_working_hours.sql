@@ -0,0 +1,4 @@
+-- Часовой пояс (IANA, пусто — UTC) и рабочие часы пользователя.
+-- working_hours: {"start": "09:00", "end": "18:00", "days": ["mon", ...]}; NULL — доступен всегда.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS working_hours JSONB;
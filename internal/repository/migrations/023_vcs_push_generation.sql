@@ -0,0 +1,4 @@
+-- Очередь отправок заполняется в транзакции изменения ревьюверов. generation растёт
+-- при каждой постановке в очередь: воркер удаляет строку, только если за время
+-- отправки её не поставили заново.
+ALTER TABLE vcs_push_queue ADD COLUMN IF NOT EXISTS generation BIGINT NOT NULL DEFAULT 0;
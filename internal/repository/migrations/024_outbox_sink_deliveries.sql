@@ -0,0 +1,8 @@
+-- Получатели, уже принявшие событие outbox. При повторе событие отправляется только
+-- тем получателям, которых здесь нет.
+CREATE TABLE IF NOT EXISTS outbox_sink_deliveries (
+    event_id     BIGINT NOT NULL REFERENCES outbox_events(id) ON DELETE CASCADE,
+    sink         TEXT NOT NULL,
+    delivered_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (event_id, sink)
+);
@@ -0,0 +1,18 @@
+-- Очередь сообщений в чат: одна строка на канал команды и событие outbox.
+-- Текст рендерится при постановке в очередь, адрес берётся из канала при отправке.
+CREATE TABLE IF NOT EXISTS chat_deliveries (
+    id              BIGSERIAL PRIMARY KEY,
+    team_name       TEXT NOT NULL REFERENCES team_chat_channels(team_name) ON UPDATE CASCADE ON DELETE CASCADE,
+    event_id        BIGINT NOT NULL,
+    text            TEXT NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'DELIVERED', 'FAILED')),
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at    TIMESTAMPTZ,
+    UNIQUE (team_name, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS chat_deliveries_due_idx ON chat_deliveries (next_attempt_at)
+    WHERE status = 'PENDING';
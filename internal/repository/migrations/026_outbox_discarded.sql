@@ -0,0 +1,17 @@
+-- DEAD-событие задерживает следующие события своего агрегата, пока его не вернут
+-- в очередь или не отбросят (статус DISCARDED), иначе они обогнали бы его.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'outbox_events_status_check'
+          AND pg_get_constraintdef(oid) NOT LIKE '%DISCARDED%'
+    ) THEN
+        ALTER TABLE outbox_events DROP CONSTRAINT outbox_events_status_check;
+        ALTER TABLE outbox_events ADD CONSTRAINT outbox_events_status_check
+            CHECK (status IN ('PENDING', 'DELIVERED', 'DEAD', 'DISCARDED'));
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS outbox_events_blocking_idx ON outbox_events (aggregate_id, id)
+    WHERE status IN ('PENDING', 'DEAD');
@@ -0,0 +1,154 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type ChatRepository struct {
+	db *sql.DB
+}
+
+func NewChatRepository(db *sql.DB) *ChatRepository {
+	return &ChatRepository{db: db}
+}
+
+func (r *ChatRepository) SetChatChannel(ctx context.Context, c domain.TeamChatChannel) (*domain.TeamChatChannel, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+        INSERT INTO team_chat_channels (team_name, webhook_url, assigned_template, deactivated_template)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (team_name) DO UPDATE
+        SET webhook_url = EXCLUDED.webhook_url,
+            assigned_template = EXCLUDED.assigned_template,
+            deactivated_template = EXCLUDED.deactivated_template,
+            updated_at = now()
+        RETURNING team_name, webhook_url, assigned_template, deactivated_template, updated_at
+    `, c.TeamName, c.WebhookURL, c.AssignedTemplate, c.DeactivatedTemplate)
+
+	var out domain.TeamChatChannel
+	if err := row.Scan(&out.TeamName, &out.WebhookURL, &out.AssignedTemplate,
+		&out.DeactivatedTemplate, &out.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("upsert chat channel: %w", err)
+	}
+	return &out, nil
+}
+
+// GetChatChannel returns nil if the team has no channel.
+func (r *ChatRepository) GetChatChannel(ctx context.Context, teamName string) (*domain.TeamChatChannel, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+        SELECT team_name, webhook_url, assigned_template, deactivated_template, updated_at
+        FROM team_chat_channels
+        WHERE team_name = $1
+    `, teamName)
+
+	var c domain.TeamChatChannel
+	err := row.Scan(&c.TeamName, &c.WebhookURL, &c.AssignedTemplate, &c.DeactivatedTemplate, &c.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("select chat channel: %w", err)
+	}
+	return &c, nil
+}
+
+func (r *ChatRepository) DeleteChatChannel(ctx context.Context, teamName string) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM team_chat_channels WHERE team_name = $1`, teamName)
+	if err != nil {
+		return fmt.Errorf("delete chat channel: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete chat channel: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// AddChatDelivery queues text for the team's channel. An event already queued
+// for the channel is skipped.
+func (r *ChatRepository) AddChatDelivery(ctx context.Context, teamName string, eventID int64, text string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO chat_deliveries (team_name, event_id, text)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (team_name, event_id) DO NOTHING
+    `, teamName, eventID, text)
+
+	if err != nil {
+		return fmt.Errorf("insert chat delivery: %w", err)
+	}
+	return nil
+}
+
+// ClaimChatDeliveries returns up to limit due deliveries with their channel's
+// current URL and pushes their next attempt lease into the future, so that
+// concurrent workers skip them while they are being sent.
+func (r *ChatRepository) ClaimChatDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.ChatDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        UPDATE chat_deliveries d
+        SET next_attempt_at = now() + make_interval(secs => $2)
+        FROM team_chat_channels c
+        WHERE c.team_name = d.team_name
+          AND d.id IN (
+              SELECT id FROM chat_deliveries
+              WHERE status = 'PENDING' AND next_attempt_at <= now()
+              ORDER BY id
+              LIMIT $1
+              FOR UPDATE SKIP LOCKED
+          )
+        RETURNING d.id, d.team_name, d.event_id, d.text, c.webhook_url, d.attempts
+    `, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim chat deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var list []domain.ChatDelivery
+	for rows.Next() {
+		var d domain.ChatDelivery
+		if err := rows.Scan(&d.ID, &d.TeamName, &d.EventID, &d.Text, &d.WebhookURL, &d.Attempts); err != nil {
+			return nil, fmt.Errorf("scan chat delivery: %w", err)
+		}
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+func (r *ChatRepository) MarkChatDelivered(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE chat_deliveries
+        SET status = 'DELIVERED', delivered_at = now(), attempts = attempts + 1, last_error = ''
+        WHERE id = $1
+    `, id)
+
+	if err != nil {
+		return fmt.Errorf("mark chat delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkChatFailed records a failed attempt and schedules the next one at next.
+// A zero next gives the delivery up.
+func (r *ChatRepository) MarkChatFailed(ctx context.Context, id int64, lastError string, next time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE chat_deliveries
+        SET attempts = attempts + 1,
+            last_error = $2,
+            status = CASE WHEN $3::timestamptz IS NULL THEN 'FAILED' ELSE 'PENDING' END,
+            next_attempt_at = COALESCE($3, next_attempt_at)
+        WHERE id = $1
+    `, id, lastError, nullTime(next))
+
+	if err != nil {
+		return fmt.Errorf("mark chat failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type EmailRepository struct {
+	db *sql.DB
+}
+
+func NewEmailRepository(db *sql.DB) *EmailRepository {
+	return &EmailRepository{db: db}
+}
+
+// QueueEmail stores m in the transaction carried by ctx. An email for the same
+// event and recipient is skipped.
+func (r *EmailRepository) QueueEmail(ctx context.Context, m domain.Email) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO email_queue (event_id, recipient, subject, text_body, html_body)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (event_id, recipient) DO NOTHING
+    `, m.EventID, m.To, m.Subject, m.Text, m.HTML)
+
+	if err != nil {
+		return fmt.Errorf("queue email: %w", err)
+	}
+	return nil
+}
+
+// ClaimEmails returns up to limit due emails and pushes their next attempt
+// lease into the future, so that concurrent workers skip them.
+func (r *EmailRepository) ClaimEmails(ctx context.Context, limit int, lease time.Duration) ([]domain.Email, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        UPDATE email_queue
+        SET next_attempt_at = now() + make_interval(secs => $2)
+        WHERE id IN (
+            SELECT id FROM email_queue
+            WHERE status = 'PENDING' AND next_attempt_at <= now()
+            ORDER BY id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING id, event_id, recipient, subject, text_body, html_body, attempts
+    `, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim emails: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.Email, 0)
+	for rows.Next() {
+		var m domain.Email
+		if err := rows.Scan(&m.ID, &m.EventID, &m.To, &m.Subject, &m.Text, &m.HTML, &m.Attempts); err != nil {
+			return nil, fmt.Errorf("scan email: %w", err)
+		}
+		list = append(list, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return list, nil
+}
+
+func (r *EmailRepository) MarkEmailSent(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE email_queue
+        SET status = 'SENT', sent_at = now(), attempts = attempts + 1, last_error = ''
+        WHERE id = $1
+    `, id)
+
+	if err != nil {
+		return fmt.Errorf("mark sent: %w", err)
+	}
+	return nil
+}
+
+// MarkEmailFailed records a failed attempt and schedules the next one at
+// next. A zero next gives the email up.
+func (r *EmailRepository) MarkEmailFailed(ctx context.Context, id int64, lastError string, next time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE email_queue
+        SET attempts = attempts + 1,
+            last_error = $2,
+            status = CASE WHEN $3::timestamptz IS NULL THEN 'FAILED' ELSE 'PENDING' END,
+            next_attempt_at = COALESCE($3, next_attempt_at)
+        WHERE id = $1
+    `, id, lastError, nullTime(next))
+
+	if err != nil {
+		return fmt.Errorf("mark failed: %w", err)
+	}
+	return nil
+}
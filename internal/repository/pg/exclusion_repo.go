@@ -0,0 +1,111 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type ExclusionRepository struct {
+	db *sql.DB
+}
+
+func NewExclusionRepository(db *sql.DB) *ExclusionRepository {
+	return &ExclusionRepository{db: db}
+}
+
+var ErrExclusionExists = errors.New("exclusion exists")
+
+func (r *ExclusionRepository) AddExclusion(ctx context.Context, e domain.ReviewerExclusion) (*domain.ReviewerExclusion, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+        INSERT INTO reviewer_exclusions (author_id, team_name, reviewer_id, reason)
+        VALUES (NULLIF($1, ''), NULLIF($2, ''), $3, $4)
+        RETURNING id, created_at
+    `, e.AuthorID, e.TeamName, e.ReviewerID, e.Reason)
+
+	if err := row.Scan(&e.ID, &e.CreatedAt); err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrExclusionExists
+		}
+		return nil, fmt.Errorf("insert exclusion: %w", err)
+	}
+	return &e, nil
+}
+
+func (r *ExclusionRepository) DeleteExclusion(ctx context.Context, id int64) (*domain.ReviewerExclusion, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+        DELETE FROM reviewer_exclusions
+        WHERE id = $1
+        RETURNING id, COALESCE(author_id, ''), COALESCE(team_name, ''), reviewer_id, reason, created_at
+    `, id)
+
+	var e domain.ReviewerExclusion
+	if err := row.Scan(&e.ID, &e.AuthorID, &e.TeamName, &e.ReviewerID, &e.Reason, &e.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("delete exclusion: %w", err)
+	}
+	return &e, nil
+}
+
+// ListExclusions returns the rules that mention userID as author, reviewer or
+// through teamName. Empty arguments match everything.
+func (r *ExclusionRepository) ListExclusions(ctx context.Context, userID, teamName string) ([]domain.ReviewerExclusion, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, COALESCE(author_id, ''), COALESCE(team_name, ''), reviewer_id, reason, created_at
+        FROM reviewer_exclusions
+        WHERE ($1 = '' OR author_id = $1 OR reviewer_id = $1)
+          AND ($2 = '' OR team_name = $2)
+        ORDER BY id
+    `, userID, teamName)
+	if err != nil {
+		return nil, fmt.Errorf("list exclusions: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.ReviewerExclusion, 0)
+	for rows.Next() {
+		var e domain.ReviewerExclusion
+		if err := rows.Scan(&e.ID, &e.AuthorID, &e.TeamName, &e.ReviewerID, &e.Reason, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan exclusion: %w", err)
+		}
+		list = append(list, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return list, nil
+}
+
+// ExcludedReviewers returns every user that must not review a PR by authorID,
+// whether excluded for the author personally or for any of the author's teams.
+func (r *ExclusionRepository) ExcludedReviewers(ctx context.Context, authorID string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT e.reviewer_id
+        FROM reviewer_exclusions e
+        WHERE e.author_id = $1
+        UNION
+        SELECT e.reviewer_id
+        FROM reviewer_exclusions e
+        JOIN team_members m ON m.team_name = e.team_name
+        WHERE m.user_id = $1
+    `, authorID)
+	if err != nil {
+		return nil, fmt.Errorf("list excluded reviewers: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan reviewer: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,61 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// LeaderLock elects one leader among replicas with a session-level Postgres
+// advisory lock. The lock lives as long as the connection holding it, so a
+// crashed leader frees it automatically.
+type LeaderLock struct {
+	db   *sql.DB
+	key  int64
+	conn *sql.Conn
+}
+
+func NewLeaderLock(db *sql.DB, key int64) *LeaderLock {
+	return &LeaderLock{db: db, key: key}
+}
+
+// Acquire reports whether this process holds the lock, taking it if it is
+// free. It is meant to be called before every unit of leader-only work; a
+// lost connection gives up leadership until the lock is taken again.
+func (l *LeaderLock) Acquire(ctx context.Context) (bool, error) {
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	c, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("leader conn: %w", err)
+	}
+
+	var ok bool
+	if err := c.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, l.key).Scan(&ok); err != nil {
+		c.Close()
+		return false, fmt.Errorf("try advisory lock: %w", err)
+	}
+	if !ok {
+		c.Close()
+		return false, nil
+	}
+
+	l.conn = c
+	return true, nil
+}
+
+// Release gives up leadership.
+func (l *LeaderLock) Release(ctx context.Context) {
+	if l.conn == nil {
+		return
+	}
+	l.conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, l.key)
+	l.conn.Close()
+	l.conn = nil
+}
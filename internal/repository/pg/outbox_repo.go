@@ -0,0 +1,202 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// AddOutboxEvent stores e in the transaction carried by ctx, if any.
+func (r *OutboxRepository) AddOutboxEvent(ctx context.Context, e domain.OutboxEvent) error {
+	payload, err := json.Marshal(e.Payload)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	_, err = conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO outbox_events (event_type, aggregate_id, payload)
+        VALUES ($1, $2, $3)
+    `, e.Type, e.AggregateID, payload)
+
+	if err != nil {
+		return fmt.Errorf("insert event: %w", err)
+	}
+	return nil
+}
+
+// outboxColumns selects an outbox event aliased o together with the sinks
+// that have accepted it.
+const outboxColumns = `o.id, o.event_type, o.aggregate_id, o.payload, o.status, o.attempts, o.last_error, o.created_at,
+        COALESCE((SELECT string_agg(d.sink, ',' ORDER BY d.sink)
+                  FROM outbox_sink_deliveries d WHERE d.event_id = o.id), '')`
+
+// ClaimOutboxEvents returns up to limit due events and pushes their next
+// attempt lease into the future, so that concurrent dispatchers skip them
+// while they are being delivered. Only the oldest pending event of each
+// aggregate is eligible, and none while the aggregate has a dead event, which
+// keeps them in order.
+func (r *OutboxRepository) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        UPDATE outbox_events o
+        SET next_attempt_at = now() + make_interval(secs => $2)
+        WHERE o.id IN (
+            SELECT c.id FROM outbox_events c
+            WHERE c.status = 'PENDING'
+              AND c.next_attempt_at <= now()
+              AND NOT EXISTS (
+                  SELECT 1 FROM outbox_events p
+                  WHERE p.aggregate_id = c.aggregate_id AND p.status IN ('PENDING', 'DEAD') AND p.id < c.id
+              )
+            ORDER BY c.id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING `+outboxColumns,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim events: %w", err)
+	}
+	return scanOutboxEvents(rows)
+}
+
+// MarkSinkDelivered records that sink has accepted event id.
+func (r *OutboxRepository) MarkSinkDelivered(ctx context.Context, id int64, sink string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO outbox_sink_deliveries (event_id, sink)
+        VALUES ($1, $2)
+        ON CONFLICT DO NOTHING
+    `, id, sink)
+
+	if err != nil {
+		return fmt.Errorf("mark sink delivered: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepository) MarkOutboxDelivered(ctx context.Context, id int64) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+        UPDATE outbox_events
+        SET status = 'DELIVERED', delivered_at = now(), attempts = attempts + 1, last_error = ''
+        WHERE id = $1
+    `, id)
+
+	if err != nil {
+		return fmt.Errorf("mark delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkOutboxFailed records a failed attempt and schedules the next one at
+// next. A zero next moves the event to the dead letters.
+func (r *OutboxRepository) MarkOutboxFailed(ctx context.Context, id int64, lastError string, next time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+        UPDATE outbox_events
+        SET attempts = attempts + 1,
+            last_error = $2,
+            status = CASE WHEN $3::timestamptz IS NULL THEN 'DEAD' ELSE 'PENDING' END,
+            next_attempt_at = COALESCE($3, next_attempt_at)
+        WHERE id = $1
+    `, id, lastError, nullTime(next))
+
+	if err != nil {
+		return fmt.Errorf("mark failed: %w", err)
+	}
+	return nil
+}
+
+func (r *OutboxRepository) ListOutboxEvents(ctx context.Context, status domain.OutboxStatus, limit int) ([]domain.OutboxEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+outboxColumns+`
+        FROM outbox_events o
+        WHERE ($1 = '' OR o.status = $1)
+        ORDER BY o.id DESC
+        LIMIT $2
+    `, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list events: %w", err)
+	}
+	return scanOutboxEvents(rows)
+}
+
+// RequeueOutboxEvent gives a dead event a fresh set of attempts. Sinks that
+// have accepted it are not sent it again.
+func (r *OutboxRepository) RequeueOutboxEvent(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE outbox_events
+        SET status = 'PENDING', attempts = 0, next_attempt_at = now()
+        WHERE id = $1 AND status = 'DEAD'
+    `, id)
+	if err != nil {
+		return fmt.Errorf("requeue event: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("requeue event: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DiscardOutboxEvent gives a dead event up for good, so that the later events
+// of its aggregate are delivered.
+func (r *OutboxRepository) DiscardOutboxEvent(ctx context.Context, id int64) error {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE outbox_events
+        SET status = 'DISCARDED'
+        WHERE id = $1 AND status = 'DEAD'
+    `, id)
+	if err != nil {
+		return fmt.Errorf("discard event: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("discard event: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanOutboxEvents(rows *sql.Rows) ([]domain.OutboxEvent, error) {
+	defer rows.Close()
+
+	list := make([]domain.OutboxEvent, 0)
+	for rows.Next() {
+		var e domain.OutboxEvent
+		var payload []byte
+		var sinks string
+		if err := rows.Scan(&e.ID, &e.Type, &e.AggregateID, &payload, &e.Status,
+			&e.Attempts, &e.LastError, &e.CreatedAt, &sinks); err != nil {
+			return nil, fmt.Errorf("scan event: %w", err)
+		}
+		if sinks != "" {
+			e.DeliveredSinks = strings.Split(sinks, ",")
+		}
+		if err := json.Unmarshal(payload, &e.Payload); err != nil {
+			return nil, fmt.Errorf("decode event %d: %w", e.ID, err)
+		}
+		list = append(list, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return list, nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/egoisthemain/pr-reviewer/internal/domain"
 )
@@ -18,11 +19,12 @@ func NewPRRepository(db *sql.DB) *PRRepository {
 }
 
 var (
-	ErrPRNotFound = errors.New("pull request not found")
+	ErrPRNotFound  = errors.New("pull request not found")
+	ErrNotAssigned = errors.New("reviewer is not assigned to the pull request")
 )
 
 func (r *PRRepository) CreatePR(ctx context.Context, pr domain.PullRequest) error {
-	_, err := r.db.ExecContext(ctx, `
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
         INSERT INTO pull_requests (pull_request_id, pull_request_name, author_id, status)
         VALUES ($1, $2, $3, 'OPEN')
     `, pr.PullRequestID, pr.PullRequestName, pr.AuthorID)
@@ -34,17 +36,28 @@ func (r *PRRepository) CreatePR(ctx context.Context, pr domain.PullRequest) erro
 }
 
 func (r *PRRepository) GetPR(ctx context.Context, prID string) (*domain.PullRequest, error) {
-	row := r.db.QueryRowContext(ctx, `
-        SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at
+	return r.getPR(ctx, prID, "")
+}
+
+// GetPRForUpdate is GetPR that also locks the PR row until the transaction
+// carried by ctx ends, so that concurrent reviewer changes to the PR are
+// serialised.
+func (r *PRRepository) GetPRForUpdate(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	return r.getPR(ctx, prID, "FOR UPDATE")
+}
+
+func (r *PRRepository) getPR(ctx context.Context, prID, lock string) (*domain.PullRequest, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+        SELECT pull_request_id, pull_request_name, author_id, status, created_at, merged_at, closed_at, version
         FROM pull_requests
         WHERE pull_request_id = $1
-    `, prID)
+    `+lock, prID)
 
 	var pr domain.PullRequest
-	var mergedAt sql.NullTime
+	var mergedAt, closedAt sql.NullTime
 
 	if err := row.Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID,
-		&pr.Status, &pr.CreatedAt, &mergedAt); err != nil {
+		&pr.Status, &pr.CreatedAt, &mergedAt, &closedAt, &pr.Version); err != nil {
 
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrPRNotFound
@@ -55,6 +68,9 @@ func (r *PRRepository) GetPR(ctx context.Context, prID string) (*domain.PullRequ
 	if mergedAt.Valid {
 		pr.MergedAt = &mergedAt.Time
 	}
+	if closedAt.Valid {
+		pr.ClosedAt = &closedAt.Time
+	}
 
 	reviewers, err := r.ListReviewers(ctx, prID)
 	if err != nil {
@@ -62,11 +78,17 @@ func (r *PRRepository) GetPR(ctx context.Context, prID string) (*domain.PullRequ
 	}
 	pr.AssignedReviewers = reviewers
 
+	shadows, err := r.ListShadowReviewers(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("select shadow reviewers: %w", err)
+	}
+	pr.ShadowReviewers = shadows
+
 	return &pr, nil
 }
 
 func (r *PRRepository) AddReviewer(ctx context.Context, prID string, userID string) error {
-	_, err := r.db.ExecContext(ctx, `
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
         INSERT INTO pr_reviewers (pull_request_id, user_id)
         VALUES ($1, $2)
         ON CONFLICT DO NOTHING
@@ -78,24 +100,53 @@ func (r *PRRepository) AddReviewer(ctx context.Context, prID string, userID stri
 	return nil
 }
 
+func (r *PRRepository) AddShadowReviewer(ctx context.Context, prID string, userID string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO pr_reviewers (pull_request_id, user_id, role)
+        VALUES ($1, $2, 'SHADOW')
+        ON CONFLICT DO NOTHING
+    `, prID, userID)
+
+	if err != nil {
+		return fmt.Errorf("add shadow reviewer: %w", err)
+	}
+	return nil
+}
+
+// RemoveReviewer fails with ErrNotAssigned when userID is not on the PR.
 func (r *PRRepository) RemoveReviewer(ctx context.Context, prID string, userID string) error {
-	_, err := r.db.ExecContext(ctx, `
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
         DELETE FROM pr_reviewers
         WHERE pull_request_id = $1 AND user_id = $2
     `, prID, userID)
+	if err != nil {
+		return fmt.Errorf("remove reviewer: %w", err)
+	}
 
+	n, err := res.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("remove reviewer: %w", err)
 	}
+	if n == 0 {
+		return ErrNotAssigned
+	}
 	return nil
 }
 
 func (r *PRRepository) ListReviewers(ctx context.Context, prID string) ([]string, error) {
-	rows, err := r.db.QueryContext(ctx, `
+	return r.listReviewersByRole(ctx, prID, "REVIEWER")
+}
+
+func (r *PRRepository) ListShadowReviewers(ctx context.Context, prID string) ([]string, error) {
+	return r.listReviewersByRole(ctx, prID, "SHADOW")
+}
+
+func (r *PRRepository) listReviewersByRole(ctx context.Context, prID string, role string) ([]string, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
         SELECT user_id
         FROM pr_reviewers
-        WHERE pull_request_id = $1
-    `, prID)
+        WHERE pull_request_id = $1 AND role = $2
+    `, prID, role)
 	if err != nil {
 		return nil, fmt.Errorf("list reviewers: %w", err)
 	}
@@ -112,8 +163,53 @@ func (r *PRRepository) ListReviewers(ctx context.Context, prID string) ([]string
 	return reviewers, nil
 }
 
+// ListReviewerDetails returns the reviewers of prID with their decisions in
+// assignment order.
+func (r *PRRepository) ListReviewerDetails(ctx context.Context, prID string) ([]domain.PRReviewer, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+        SELECT r.user_id, u.username, r.role, r.assigned_at, COALESCE(r.decision, ''), r.decided_at
+        FROM pr_reviewers r
+        JOIN users u ON u.user_id = r.user_id
+        WHERE r.pull_request_id = $1
+        ORDER BY r.assigned_at, r.user_id
+    `, prID)
+	if err != nil {
+		return nil, fmt.Errorf("list reviewer details: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.PRReviewer, 0)
+	for rows.Next() {
+		var rv domain.PRReviewer
+		var decidedAt sql.NullTime
+
+		if err := rows.Scan(&rv.UserID, &rv.Username, &rv.Role, &rv.AssignedAt,
+			&rv.Decision, &decidedAt); err != nil {
+			return nil, fmt.Errorf("scan reviewer: %w", err)
+		}
+		if decidedAt.Valid {
+			rv.DecidedAt = &decidedAt.Time
+		}
+
+		list = append(list, rv)
+	}
+	return list, rows.Err()
+}
+
+func (r *PRRepository) AddEvent(ctx context.Context, prID string, eventType domain.PREventType, userID string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO pr_events (pull_request_id, event_type, user_id)
+        VALUES ($1, $2, NULLIF($3, ''))
+    `, prID, eventType, userID)
+
+	if err != nil {
+		return fmt.Errorf("add event: %w", err)
+	}
+	return nil
+}
+
 func (r *PRRepository) SetMerged(ctx context.Context, prID string) error {
-	_, err := r.db.ExecContext(ctx, `
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
         UPDATE pull_requests
         SET status = 'MERGED', merged_at = now()
         WHERE pull_request_id = $1
@@ -125,37 +221,208 @@ func (r *PRRepository) SetMerged(ctx context.Context, prID string) error {
 	return nil
 }
 
-func (r *PRRepository) ListPRsByReviewer(ctx context.Context, userID string) ([]domain.PullRequest, error) {
-	rows, err := r.db.QueryContext(ctx, `
-        SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status, pr.created_at, pr.merged_at
-        FROM pr_reviewers r
-        JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
-        WHERE r.user_id = $1
-    `, userID)
+func (r *PRRepository) SetClosed(ctx context.Context, prID string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+        UPDATE pull_requests
+        SET status = 'CLOSED', closed_at = now()
+        WHERE pull_request_id = $1
+    `, prID)
+
 	if err != nil {
-		return nil, fmt.Errorf("list prs by reviewer: %w", err)
+		return fmt.Errorf("close pr: %w", err)
 	}
-	defer rows.Close()
+	return nil
+}
 
-	var list []domain.PullRequest
+func (r *PRRepository) SetReopened(ctx context.Context, prID string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+        UPDATE pull_requests
+        SET status = 'OPEN', closed_at = NULL
+        WHERE pull_request_id = $1
+    `, prID)
 
+	if err != nil {
+		return fmt.Errorf("reopen pr: %w", err)
+	}
+	return nil
+}
+
+func (r *PRRepository) PRExists(ctx context.Context, prID string) (bool, error) {
+	var exists bool
+	err := conn(ctx, r.db).QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM pull_requests WHERE pull_request_id = $1)
+    `, prID).Scan(&exists)
+
+	if err != nil {
+		return false, fmt.Errorf("pr exists: %w", err)
+	}
+	return exists, nil
+}
+
+func (r *PRRepository) SetDecision(ctx context.Context, prID, userID string, decision domain.ReviewDecision) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+        UPDATE pr_reviewers
+        SET decision = $3, decided_at = now()
+        WHERE pull_request_id = $1 AND user_id = $2
+    `, prID, userID, decision)
+	if err != nil {
+		return fmt.Errorf("set decision: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("set decision: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListPRs returns up to f.Limit PRs matching f in f.Sort order, starting after
+// f.After, with their reviewers loaded.
+func (r *PRRepository) ListPRs(ctx context.Context, f domain.PRListFilter) ([]domain.PullRequest, error) {
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.Status != "" {
+		where = append(where, "pr.status = "+arg(f.Status))
+	}
+	if f.AuthorID != "" {
+		where = append(where, "pr.author_id = "+arg(f.AuthorID))
+	}
+	if f.ReviewerID != "" {
+		extra := ""
+		if f.PendingReview {
+			extra += " AND r.decision IS NULL"
+		}
+		if f.AssignedOnly {
+			extra += " AND r.role = 'REVIEWER'"
+		}
+		where = append(where, `EXISTS (SELECT 1 FROM pr_reviewers r
+            WHERE r.pull_request_id = pr.pull_request_id AND r.user_id = `+arg(f.ReviewerID)+extra+`)`)
+	}
+	if f.TeamName != "" {
+		where = append(where, `EXISTS (SELECT 1 FROM team_members m
+            WHERE m.user_id = pr.author_id AND m.team_name = `+arg(f.TeamName)+`)`)
+	}
+	if f.CreatedFrom != nil {
+		where = append(where, "pr.created_at >= "+arg(*f.CreatedFrom))
+	}
+	if f.CreatedTo != nil {
+		where = append(where, "pr.created_at < "+arg(*f.CreatedTo))
+	}
+
+	var order string
+	switch f.Sort {
+	case domain.SortCreatedDesc:
+		order = "pr.created_at DESC, pr.pull_request_id DESC"
+		if f.After != nil {
+			where = append(where, "(pr.created_at, pr.pull_request_id) < ("+arg(f.After.CreatedAt)+", "+arg(f.After.PullRequestID)+")")
+		}
+	case domain.SortIDAsc:
+		order = "pr.pull_request_id"
+		if f.After != nil {
+			where = append(where, "pr.pull_request_id > "+arg(f.After.PullRequestID))
+		}
+	case domain.SortIDDesc:
+		order = "pr.pull_request_id DESC"
+		if f.After != nil {
+			where = append(where, "pr.pull_request_id < "+arg(f.After.PullRequestID))
+		}
+	default:
+		order = "pr.created_at, pr.pull_request_id"
+		if f.After != nil {
+			where = append(where, "(pr.created_at, pr.pull_request_id) > ("+arg(f.After.CreatedAt)+", "+arg(f.After.PullRequestID)+")")
+		}
+	}
+
+	query := `
+        SELECT pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status, pr.created_at, pr.merged_at, pr.closed_at
+        FROM pull_requests pr`
+	if len(where) > 0 {
+		query += "\n        WHERE " + strings.Join(where, "\n          AND ")
+	}
+	query += "\n        ORDER BY " + order + "\n        LIMIT " + arg(f.Limit)
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list prs: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.PullRequest, 0)
 	for rows.Next() {
 		var pr domain.PullRequest
-		var mergedAt sql.NullTime
-
-		err := rows.Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID,
-			&pr.Status, &pr.CreatedAt, &mergedAt)
+		var mergedAt, closedAt sql.NullTime
 
-		if err != nil {
+		if err := rows.Scan(&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID,
+			&pr.Status, &pr.CreatedAt, &mergedAt, &closedAt); err != nil {
 			return nil, fmt.Errorf("scan pr: %w", err)
 		}
-
 		if mergedAt.Valid {
 			pr.MergedAt = &mergedAt.Time
 		}
+		if closedAt.Valid {
+			pr.ClosedAt = &closedAt.Time
+		}
 
 		list = append(list, pr)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
 
+	if err := r.loadReviewers(ctx, list); err != nil {
+		return nil, err
+	}
 	return list, nil
 }
+
+// loadReviewers fills AssignedReviewers and ShadowReviewers of prs with one
+// query.
+func (r *PRRepository) loadReviewers(ctx context.Context, prs []domain.PullRequest) error {
+	if len(prs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(prs))
+	index := make(map[string]int, len(prs))
+	for i := range prs {
+		ids[i] = prs[i].PullRequestID
+		index[prs[i].PullRequestID] = i
+		prs[i].AssignedReviewers = []string{}
+	}
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+        SELECT pull_request_id, user_id, role
+        FROM pr_reviewers
+        WHERE pull_request_id = ANY($1::text[])
+        ORDER BY assigned_at, user_id
+    `, ids)
+	if err != nil {
+		return fmt.Errorf("load reviewers: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var prID, userID, role string
+		if err := rows.Scan(&prID, &userID, &role); err != nil {
+			return fmt.Errorf("scan reviewer: %w", err)
+		}
+
+		pr := &prs[index[prID]]
+		if role == "SHADOW" {
+			pr.ShadowReviewers = append(pr.ShadowReviewers, userID)
+		} else {
+			pr.AssignedReviewers = append(pr.AssignedReviewers, userID)
+		}
+	}
+	return rows.Err()
+}
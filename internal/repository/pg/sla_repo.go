@@ -0,0 +1,123 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type SLARepository struct {
+	db *sql.DB
+}
+
+func NewSLARepository(db *sql.DB) *SLARepository {
+	return &SLARepository{db: db}
+}
+
+// ListPendingReviews returns the undecided assignments on open PRs whose
+// author is in a team with an SLA, with the reviewer's work schedule. Of
+// several teams the smallest non-zero threshold wins.
+func (r *SLARepository) ListPendingReviews(ctx context.Context) ([]domain.PendingReview, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT r.pull_request_id, r.user_id, r.assigned_at,
+               COALESCE(s.reminder, 0), COALESCE(s.reassign, 0), u.timezone, u.working_hours,
+               EXISTS (
+                   SELECT 1 FROM sla_actions a
+                   WHERE a.pull_request_id = r.pull_request_id AND a.user_id = r.user_id
+                     AND a.assigned_at = r.assigned_at AND a.action = 'REMINDER'
+               ),
+               EXISTS (
+                   SELECT 1 FROM sla_actions a
+                   WHERE a.pull_request_id = r.pull_request_id AND a.user_id = r.user_id
+                     AND a.assigned_at = r.assigned_at AND a.action = 'REASSIGN'
+               )
+        FROM pr_reviewers r
+        JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+        JOIN users u ON u.user_id = r.user_id
+        CROSS JOIN LATERAL (
+            SELECT MIN(NULLIF(t.sla_reminder_minutes, 0)) AS reminder,
+                   MIN(NULLIF(t.sla_reassign_minutes, 0)) AS reassign
+            FROM team_members m
+            JOIN teams t ON t.team_name = m.team_name
+            WHERE m.user_id = pr.author_id
+        ) s
+        WHERE pr.status = 'OPEN'
+          AND r.role = 'REVIEWER'
+          AND r.decision IS NULL
+          AND (s.reminder IS NOT NULL OR s.reassign IS NOT NULL)
+        ORDER BY r.assigned_at
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("list pending reviews: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.PendingReview, 0)
+	for rows.Next() {
+		var p domain.PendingReview
+		var hours []byte
+		if err := rows.Scan(&p.PullRequestID, &p.UserID, &p.AssignedAt,
+			&p.SLA.ReminderMinutes, &p.SLA.ReassignMinutes, &p.Schedule.Timezone, &hours,
+			&p.Reminded, &p.Escalated); err != nil {
+			return nil, fmt.Errorf("scan pending review: %w", err)
+		}
+		if p.Schedule.WorkingHours, err = decodeWorkingHours(hours); err != nil {
+			return nil, err
+		}
+		list = append(list, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return list, nil
+}
+
+// AddSLAAction records a in the transaction carried by ctx. It reports false
+// when the same action on the same assignment is already recorded.
+func (r *SLARepository) AddSLAAction(ctx context.Context, a domain.SLAAction) (bool, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO sla_actions (pull_request_id, user_id, action, assigned_at, new_user_id, error)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (pull_request_id, user_id, assigned_at, action) DO NOTHING
+    `, a.PullRequestID, a.UserID, a.Action, a.AssignedAt, a.NewUserID, a.Error)
+	if err != nil {
+		return false, fmt.Errorf("insert sla action: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("insert sla action: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListSLAActions returns the newest actions first, optionally for one PR.
+func (r *SLARepository) ListSLAActions(ctx context.Context, prID string, limit int) ([]domain.SLAAction, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, pull_request_id, user_id, action, assigned_at, new_user_id, error, created_at
+        FROM sla_actions
+        WHERE ($1 = '' OR pull_request_id = $1)
+        ORDER BY id DESC
+        LIMIT $2
+    `, prID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list sla actions: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.SLAAction, 0)
+	for rows.Next() {
+		var a domain.SLAAction
+		if err := rows.Scan(&a.ID, &a.PullRequestID, &a.UserID, &a.Action, &a.AssignedAt,
+			&a.NewUserID, &a.Error, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan sla action: %w", err)
+		}
+		list = append(list, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return list, nil
+}
@@ -0,0 +1,247 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type StatsRepository struct {
+	db *sql.DB
+}
+
+func NewStatsRepository(db *sql.DB) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+func (r *StatsRepository) ReviewerStats(ctx context.Context, f domain.StatsFilter) ([]domain.ReviewerStats, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        WITH members AS (
+            SELECT u.user_id, u.username
+            FROM users u
+            WHERE COALESCE(cardinality($3::text[]), 0) = 0
+               OR EXISTS (
+                   SELECT 1 FROM team_members m
+                   WHERE m.user_id = u.user_id AND m.team_name = ANY($3::text[])
+               )
+        ),
+        events AS (
+            SELECT user_id,
+                   count(*) FILTER (WHERE event_type = 'ASSIGNED')        AS assignments,
+                   count(*) FILTER (WHERE event_type = 'REASSIGNED_AWAY') AS reassigned_away
+            FROM pr_events
+            WHERE user_id IS NOT NULL AND created_at >= $1 AND created_at < $2
+            GROUP BY user_id
+        ),
+        load AS (
+            SELECT r.user_id, count(*) AS open_reviews
+            FROM pr_reviewers r
+            JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+            WHERE pr.status = 'OPEN' AND r.role = 'REVIEWER'
+            GROUP BY r.user_id
+        ),
+        merges AS (
+            SELECT r.user_id,
+                   percentile_cont(0.5) WITHIN GROUP (
+                       ORDER BY EXTRACT(EPOCH FROM pr.merged_at - r.assigned_at)
+                   ) AS median_seconds
+            FROM pr_reviewers r
+            JOIN pull_requests pr ON pr.pull_request_id = r.pull_request_id
+            WHERE pr.status = 'MERGED' AND r.role = 'REVIEWER'
+              AND pr.merged_at >= $1 AND pr.merged_at < $2
+            GROUP BY r.user_id
+        )
+        SELECT m.user_id, m.username,
+               COALESCE(e.assignments, 0), COALESCE(l.open_reviews, 0),
+               COALESCE(e.reassigned_away, 0), g.median_seconds
+        FROM members m
+        LEFT JOIN events e ON e.user_id = m.user_id
+        LEFT JOIN load l ON l.user_id = m.user_id
+        LEFT JOIN merges g ON g.user_id = m.user_id
+        ORDER BY 3 DESC, m.user_id
+    `, f.From, f.To, f.Teams)
+	if err != nil {
+		return nil, fmt.Errorf("reviewer stats: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.ReviewerStats, 0)
+	for rows.Next() {
+		var st domain.ReviewerStats
+		var median sql.NullFloat64
+
+		if err := rows.Scan(&st.UserID, &st.Username, &st.Assignments, &st.OpenReviews,
+			&st.ReassignedAway, &median); err != nil {
+			return nil, fmt.Errorf("scan reviewer stats: %w", err)
+		}
+		if median.Valid {
+			st.MedianTimeToMerge = &median.Float64
+		}
+
+		list = append(list, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return list, nil
+}
+
+// TeamStats aggregates PRs per scope root. roots and teams are parallel
+// arrays: a PR counts for roots[i] when its author belongs to teams[i].
+func (r *StatsRepository) TeamStats(ctx context.Context, f domain.StatsFilter, roots, teams []string) ([]domain.TeamStats, error) {
+	const scoped = `
+        WITH scope(root, team_name) AS (
+            SELECT * FROM unnest($3::text[], $4::text[])
+        ),
+        authors AS (
+            SELECT DISTINCT s.root, m.user_id
+            FROM scope s
+            JOIN team_members m ON m.team_name = s.team_name
+        ),
+        prs AS (
+            SELECT DISTINCT a.root, pr.pull_request_id, pr.created_at, pr.merged_at,
+                   pr.created_at >= $1 AND pr.created_at < $2 AS created_in,
+                   COALESCE(pr.merged_at >= $1 AND pr.merged_at < $2, false) AS merged_in
+            FROM authors a
+            JOIN pull_requests pr ON pr.author_id = a.user_id
+            WHERE (pr.created_at >= $1 AND pr.created_at < $2)
+               OR (pr.merged_at >= $1 AND pr.merged_at < $2)
+        )`
+
+	rows, err := r.db.QueryContext(ctx, scoped+`,
+        reassigns AS (
+            SELECT DISTINCT a.root, e.id
+            FROM authors a
+            JOIN pull_requests pr ON pr.author_id = a.user_id
+            JOIN pr_events e ON e.pull_request_id = pr.pull_request_id
+            WHERE e.event_type = 'REASSIGNED_AWAY' AND e.created_at >= $1 AND e.created_at < $2
+        ),
+        totals AS (
+            SELECT p.root,
+                   count(*) FILTER (WHERE p.created_in) AS created,
+                   count(*) FILTER (WHERE p.merged_in)  AS merged,
+                   percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM p.merged_at - p.created_at))
+                       FILTER (WHERE p.merged_in) AS median_seconds,
+                   percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM p.merged_at - p.created_at))
+                       FILTER (WHERE p.merged_in) AS p90_seconds,
+                   count(*) FILTER (WHERE p.created_in AND NOT EXISTS (
+                       SELECT 1 FROM pr_reviewers r
+                       WHERE r.pull_request_id = p.pull_request_id AND r.role = 'REVIEWER'
+                   )) AS without_reviewers
+            FROM prs p
+            GROUP BY p.root
+        )
+        SELECT s.root,
+               COALESCE(t.created, 0), COALESCE(t.merged, 0),
+               t.median_seconds, t.p90_seconds, COALESCE(t.without_reviewers, 0),
+               (SELECT count(*) FROM reassigns ra WHERE ra.root = s.root)
+        FROM (SELECT DISTINCT root FROM scope) s
+        LEFT JOIN totals t ON t.root = s.root
+        ORDER BY s.root
+    `, f.From, f.To, roots, teams)
+	if err != nil {
+		return nil, fmt.Errorf("team stats: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.TeamStats, 0)
+	index := make(map[string]int)
+	for rows.Next() {
+		var st domain.TeamStats
+		var median, p90 sql.NullFloat64
+
+		if err := rows.Scan(&st.TeamName, &st.Created, &st.Merged, &median, &p90,
+			&st.WithoutReviewers, &st.Reassignments); err != nil {
+			return nil, fmt.Errorf("scan team stats: %w", err)
+		}
+		if median.Valid {
+			st.MedianCycleTime = &median.Float64
+		}
+		if p90.Valid {
+			st.P90CycleTime = &p90.Float64
+		}
+		st.Weeks = make([]domain.WeeklyThroughput, 0)
+
+		index[st.TeamName] = len(list)
+		list = append(list, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	weeks, err := r.db.QueryContext(ctx, scoped+`
+        SELECT root, week, sum(created), sum(merged)
+        FROM (
+            SELECT root, date_trunc('week', created_at) AS week, 1 AS created, 0 AS merged
+            FROM prs WHERE created_in
+            UNION ALL
+            SELECT root, date_trunc('week', merged_at), 0, 1
+            FROM prs WHERE merged_in
+        ) x
+        GROUP BY root, week
+        ORDER BY root, week
+    `, f.From, f.To, roots, teams)
+	if err != nil {
+		return nil, fmt.Errorf("weekly throughput: %w", err)
+	}
+	defer weeks.Close()
+
+	for weeks.Next() {
+		var root string
+		var wk domain.WeeklyThroughput
+		if err := weeks.Scan(&root, &wk.WeekStart, &wk.Created, &wk.Merged); err != nil {
+			return nil, fmt.Errorf("scan weekly throughput: %w", err)
+		}
+		if i, ok := index[root]; ok {
+			list[i].Weeks = append(list[i].Weeks, wk)
+		}
+	}
+	if err := weeks.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return list, nil
+}
+
+// AssignmentLoads returns the number of assignments in the window for every
+// active regular member of the given teams, or of all teams when teams is
+// empty. Members without assignments are included with zero. A member of
+// several teams is counted in each only for PRs whose author is in that team.
+func (r *StatsRepository) AssignmentLoads(ctx context.Context, f domain.StatsFilter) ([]domain.ReviewerLoad, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT m.team_name, u.user_id, u.username, count(e.id)
+        FROM team_members m
+        JOIN users u ON u.user_id = m.user_id
+        LEFT JOIN (pr_events e JOIN pull_requests pr ON pr.pull_request_id = e.pull_request_id)
+               ON e.user_id = u.user_id
+              AND e.event_type = 'ASSIGNED'
+              AND e.created_at >= $1 AND e.created_at < $2
+              AND EXISTS (
+                  SELECT 1 FROM team_members am
+                  WHERE am.user_id = pr.author_id AND am.team_name = m.team_name
+              )
+        WHERE u.is_active AND u.role = 'MEMBER'
+          AND (COALESCE(cardinality($3::text[]), 0) = 0 OR m.team_name = ANY($3::text[]))
+        GROUP BY m.team_name, u.user_id, u.username
+        ORDER BY m.team_name, u.user_id
+    `, f.From, f.To, f.Teams)
+	if err != nil {
+		return nil, fmt.Errorf("assignment loads: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.ReviewerLoad, 0)
+	for rows.Next() {
+		var l domain.ReviewerLoad
+		if err := rows.Scan(&l.TeamName, &l.UserID, &l.Username, &l.Assignments); err != nil {
+			return nil, fmt.Errorf("scan assignment load: %w", err)
+		}
+		list = append(list, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return list, nil
+}
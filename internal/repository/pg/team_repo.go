@@ -3,6 +3,7 @@ package pg
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -19,68 +20,336 @@ func NewTeamRepository(db *sql.DB) *TeamRepository {
 
 var ErrNotFound = errors.New("not found")
 var ErrTeamExists = errors.New("team exists")
+var ErrAlreadyMember = errors.New("user is already a member of the team")
+var ErrNotTeamMember = errors.New("user is not a member of the team")
+var ErrTeamHasOpenPRs = errors.New("team has open pull requests")
+var ErrTeamHasSubteams = errors.New("team has subteams")
+var ErrTeamCycle = errors.New("team cannot be nested under its own subtree")
 
 func (r *TeamRepository) CreateTeamWithMembers(ctx context.Context, team domain.Team) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+	return inTx(ctx, r.db, func(ctx context.Context, tx querier) error {
+		var exists bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS(SELECT 1 FROM teams WHERE team_name = $1)`,
+			team.TeamName,
+		).Scan(&exists); err != nil {
+			return fmt.Errorf("check team: %w", err)
+		}
+		if exists {
+			return ErrTeamExists
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO teams (team_name, shadow_fraction, parent_team_name, sla_reminder_minutes, sla_reassign_minutes)
+			 VALUES ($1, $2, NULLIF($3, ''), $4, $5)`,
+			team.TeamName, team.ShadowFraction, team.ParentTeam,
+			team.ReviewSLA.ReminderMinutes, team.ReviewSLA.ReassignMinutes,
+		); err != nil {
+			if isForeignKeyViolation(err) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("insert team: %w", err)
+		}
+
+		for _, u := range team.Members {
+			if _, err := addMember(ctx, tx, team.TeamName, u); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// addMember creates u unless a user with its ID exists and adds it to
+// teamName. An existing user keeps its stored name, role, activity and
+// schedule; those change only through the dedicated user calls. Memberships
+// in other teams are left untouched. It reports whether the membership is new.
+func addMember(ctx context.Context, tx querier, teamName string, u domain.User) (bool, error) {
+	role := u.Role
+	if role == "" {
+		role = domain.RoleMember
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO users (user_id, username, is_active, role, email, timezone, working_hours)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO NOTHING
+	`, u.UserID, u.Username, u.IsActive, role, u.Email, u.Timezone, workingHoursJSON(u.WorkingHours)); err != nil {
+		return false, fmt.Errorf("insert user: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO team_members (team_name, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, teamName, u.UserID)
 	if err != nil {
-		return fmt.Errorf("begin tx: %w", err)
+		return false, fmt.Errorf("insert membership: %w", err)
 	}
-	defer tx.Rollback()
 
-	var exists bool
-	if err := tx.QueryRowContext(ctx,
-		`SELECT EXISTS(SELECT 1 FROM teams WHERE team_name = $1)`,
-		team.TeamName,
-	).Scan(&exists); err != nil {
-		return fmt.Errorf("check team: %w", err)
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func (r *TeamRepository) RenameTeam(ctx context.Context, oldName, newName string) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE teams
+		SET team_name = $2
+		WHERE team_name = $1
+	`, oldName, newName)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrTeamExists
+		}
+		return fmt.Errorf("rename team: %w", err)
 	}
-	if exists {
-		return ErrTeamExists
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
 	}
+	return nil
+}
+
+// DeleteTeam removes the team together with its memberships. It fails with
+// ErrTeamHasOpenPRs while any member has an open pull request.
+func (r *TeamRepository) DeleteTeam(ctx context.Context, teamName string) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx querier) error {
+		var locked string
+		if err := tx.QueryRowContext(ctx,
+			`SELECT team_name FROM teams WHERE team_name = $1 FOR UPDATE`,
+			teamName,
+		).Scan(&locked); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("lock team: %w", err)
+		}
+
+		var openPRs bool
+		if err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS(
+				SELECT 1
+				FROM pull_requests pr
+				JOIN team_members m ON m.user_id = pr.author_id
+				WHERE m.team_name = $1 AND pr.status = 'OPEN'
+			)
+		`, teamName).Scan(&openPRs); err != nil {
+			return fmt.Errorf("check open prs: %w", err)
+		}
+		if openPRs {
+			return ErrTeamHasOpenPRs
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM teams WHERE team_name = $1`,
+			teamName,
+		); err != nil {
+			if isForeignKeyViolation(err) {
+				return ErrTeamHasSubteams
+			}
+			return fmt.Errorf("delete team: %w", err)
+		}
+		return nil
+	})
+}
 
-	if _, err := tx.ExecContext(ctx,
-		`INSERT INTO teams (team_name) VALUES ($1)`,
-		team.TeamName,
-	); err != nil {
-		return fmt.Errorf("insert team: %w", err)
+// SetParent nests teamName under parent, or makes it a root team when parent
+// is empty.
+func (r *TeamRepository) SetParent(ctx context.Context, teamName, parent string) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx querier) error {
+		if parent != "" {
+			var cycle bool
+			if err := tx.QueryRowContext(ctx, `
+				WITH RECURSIVE subtree AS (
+					SELECT team_name FROM teams WHERE team_name = $1
+					UNION
+					SELECT t.team_name FROM teams t JOIN subtree s ON t.parent_team_name = s.team_name
+				)
+				SELECT EXISTS(SELECT 1 FROM subtree WHERE team_name = $2)
+			`, teamName, parent).Scan(&cycle); err != nil {
+				return fmt.Errorf("check cycle: %w", err)
+			}
+			if cycle {
+				return ErrTeamCycle
+			}
+		}
+
+		res, err := tx.ExecContext(ctx, `
+			UPDATE teams
+			SET parent_team_name = NULLIF($2, '')
+			WHERE team_name = $1
+		`, teamName, parent)
+		if err != nil {
+			if isForeignKeyViolation(err) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("update team: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// ListSubtree returns teamName and every team below it, parents before
+// their children.
+func (r *TeamRepository) ListSubtree(ctx context.Context, teamName string) ([]string, error) {
+	return r.listTeamNames(ctx, `
+		WITH RECURSIVE subtree AS (
+			SELECT team_name, 0 AS depth FROM teams WHERE team_name = $1
+			UNION ALL
+			SELECT t.team_name, s.depth + 1
+			FROM teams t JOIN subtree s ON t.parent_team_name = s.team_name
+		)
+		SELECT team_name FROM subtree ORDER BY depth, team_name
+	`, teamName)
+}
+
+// ListAncestors returns the parents of teamName up to the root, nearest
+// first.
+func (r *TeamRepository) ListAncestors(ctx context.Context, teamName string) ([]string, error) {
+	return r.listTeamNames(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT parent_team_name AS team_name, 1 AS depth
+			FROM teams WHERE team_name = $1
+			UNION ALL
+			SELECT t.parent_team_name, a.depth + 1
+			FROM teams t JOIN ancestors a ON t.team_name = a.team_name
+		)
+		SELECT team_name FROM ancestors WHERE team_name IS NOT NULL ORDER BY depth
+	`, teamName)
+}
+
+func (r *TeamRepository) ListTeamNames(ctx context.Context) ([]string, error) {
+	return r.listTeamNames(ctx, `SELECT team_name FROM teams ORDER BY team_name`)
+}
+
+func (r *TeamRepository) listTeamNames(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list teams: %w", err)
+	}
+	defer rows.Close()
+
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scan team: %w", err)
+		}
+		names = append(names, name)
 	}
+	return names, rows.Err()
+}
+
+func (r *TeamRepository) AddMember(ctx context.Context, teamName string, u domain.User) error {
+	return inTx(ctx, r.db, func(ctx context.Context, tx querier) error {
+		var exists bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS(SELECT 1 FROM teams WHERE team_name = $1)`,
+			teamName,
+		).Scan(&exists); err != nil {
+			return fmt.Errorf("check team: %w", err)
+		}
+		if !exists {
+			return ErrNotFound
+		}
 
-	for _, u := range team.Members {
-		_, err := tx.ExecContext(ctx, `
-			INSERT INTO users (user_id, username, team_name, is_active)
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (user_id) DO UPDATE
-			  SET username = EXCLUDED.username,
-			      team_name = EXCLUDED.team_name,
-			      is_active = EXCLUDED.is_active
-		`, u.UserID, u.Username, team.TeamName, u.IsActive)
+		added, err := addMember(ctx, tx, teamName, u)
 		if err != nil {
-			return fmt.Errorf("upsert user: %w", err)
+			return err
+		}
+		if !added {
+			return ErrAlreadyMember
 		}
+		return nil
+	})
+}
+
+func (r *TeamRepository) RemoveMember(ctx context.Context, teamName, userID string) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+		DELETE FROM team_members
+		WHERE team_name = $1 AND user_id = $2
+	`, teamName, userID)
+	if err != nil {
+		return fmt.Errorf("remove member: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit: %w", err)
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotTeamMember
 	}
 	return nil
 }
 
+// MoveUser transfers the user from fromTeam to toTeam. With an empty fromTeam
+// the user leaves every other team and stays in toTeam only.
+func (r *TeamRepository) MoveUser(ctx context.Context, userID, fromTeam, toTeam string) (*domain.User, error) {
+	var u *domain.User
+	err := inTx(ctx, r.db, func(ctx context.Context, tx querier) error {
+		var exists bool
+		if err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS(SELECT 1 FROM teams WHERE team_name = $1)`,
+			toTeam,
+		).Scan(&exists); err != nil {
+			return fmt.Errorf("check team: %w", err)
+		}
+		if !exists {
+			return ErrNotFound
+		}
+
+		res, err := tx.ExecContext(ctx, `
+			DELETE FROM team_members
+			WHERE user_id = $1 AND ($2 = '' OR team_name = $2) AND team_name <> $3
+		`, userID, fromTeam, toTeam)
+		if err != nil {
+			return fmt.Errorf("leave team: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 && fromTeam != "" {
+			return ErrNotTeamMember
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO team_members (team_name, user_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, toTeam, userID); err != nil {
+			if isForeignKeyViolation(err) {
+				return ErrNotFound
+			}
+			return fmt.Errorf("join team: %w", err)
+		}
+
+		u, err = r.GetUser(ctx, userID)
+		return err
+	})
+	return u, err
+}
+
+func (r *TeamRepository) SetUsername(ctx context.Context, userID, username string) (*domain.User, error) {
+	return r.updateUser(ctx, `UPDATE users SET username = $2 WHERE user_id = $1`, userID, username)
+}
+
 func (r *TeamRepository) GetTeam(ctx context.Context, teamName string) (*domain.Team, error) {
-	var exists bool
-	if err := r.db.QueryRowContext(ctx,
-		`SELECT EXISTS(SELECT 1 FROM teams WHERE team_name = $1)`,
+	var shadowFraction float64
+	var parent string
+	var sla domain.ReviewSLA
+	if err := conn(ctx, r.db).QueryRowContext(ctx,
+		`SELECT shadow_fraction, COALESCE(parent_team_name, ''), sla_reminder_minutes, sla_reassign_minutes
+		 FROM teams WHERE team_name = $1`,
 		teamName,
-	).Scan(&exists); err != nil {
+	).Scan(&shadowFraction, &parent, &sla.ReminderMinutes, &sla.ReassignMinutes); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
 		return nil, fmt.Errorf("check team: %w", err)
 	}
-	if !exists {
-		return nil, ErrNotFound
-	}
 
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT user_id, username, is_active
-		FROM users
-		WHERE team_name = $1
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT u.user_id, u.username, u.is_active, u.role, u.email, u.timezone, u.working_hours
+		FROM team_members m
+		JOIN users u ON u.user_id = m.user_id
+		WHERE m.team_name = $1
 	`, teamName)
 	if err != nil {
 		return nil, fmt.Errorf("select members: %w", err)
@@ -91,9 +360,13 @@ func (r *TeamRepository) GetTeam(ctx context.Context, teamName string) (*domain.
 	for rows.Next() {
 		var u domain.User
 		u.TeamName = teamName
-		if err := rows.Scan(&u.UserID, &u.Username, &u.IsActive); err != nil {
+		var hours []byte
+		if err := rows.Scan(&u.UserID, &u.Username, &u.IsActive, &u.Role, &u.Email, &u.Timezone, &hours); err != nil {
 			return nil, fmt.Errorf("scan: %w", err)
 		}
+		if u.WorkingHours, err = decodeWorkingHours(hours); err != nil {
+			return nil, err
+		}
 		members = append(members, u)
 	}
 	if err := rows.Err(); err != nil {
@@ -101,51 +374,176 @@ func (r *TeamRepository) GetTeam(ctx context.Context, teamName string) (*domain.
 	}
 
 	return &domain.Team{
-		TeamName: teamName,
-		Members:  members,
+		TeamName:       teamName,
+		Members:        members,
+		ShadowFraction: shadowFraction,
+		ParentTeam:     parent,
+		ReviewSLA:      sla,
 	}, nil
 }
 
 func (r *TeamRepository) SetUserActive(ctx context.Context, userID string, isActive bool) (*domain.User, error) {
-	row := r.db.QueryRowContext(ctx, `
-		UPDATE users
-		SET is_active = $2
+	return r.updateUser(ctx, `UPDATE users SET is_active = $2 WHERE user_id = $1`, userID, isActive)
+}
+
+func (r *TeamRepository) SetEmail(ctx context.Context, userID, email string) (*domain.User, error) {
+	return r.updateUser(ctx, `UPDATE users SET email = $2 WHERE user_id = $1`, userID, email)
+}
+
+func (r *TeamRepository) SetWorkSchedule(ctx context.Context, userID string, ws domain.WorkSchedule) (*domain.User, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE users SET timezone = $2, working_hours = $3 WHERE user_id = $1
+	`, userID, ws.Timezone, workingHoursJSON(ws.WorkingHours))
+	if err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+	return r.GetUser(ctx, userID)
+}
+
+// workingHoursJSON encodes h for the working_hours column, nil as NULL.
+func workingHoursJSON(h *domain.WorkingHours) []byte {
+	if h == nil {
+		return nil
+	}
+	b, _ := json.Marshal(h)
+	return b
+}
+
+func decodeWorkingHours(b []byte) (*domain.WorkingHours, error) {
+	if b == nil {
+		return nil, nil
+	}
+	var h domain.WorkingHours
+	if err := json.Unmarshal(b, &h); err != nil {
+		return nil, fmt.Errorf("decode working hours: %w", err)
+	}
+	return &h, nil
+}
+
+func (r *TeamRepository) SetUserRole(ctx context.Context, userID string, role domain.UserRole) (*domain.User, error) {
+	return r.updateUser(ctx, `UPDATE users SET role = $2 WHERE user_id = $1`, userID, role)
+}
+
+func (r *TeamRepository) updateUser(ctx context.Context, query string, userID string, value any) (*domain.User, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, userID, value)
+	if err != nil {
+		return nil, fmt.Errorf("update: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+	return r.GetUser(ctx, userID)
+}
+
+func (r *TeamRepository) SetReviewSLA(ctx context.Context, teamName string, sla domain.ReviewSLA) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE teams
+		SET sla_reminder_minutes = $2, sla_reassign_minutes = $3
+		WHERE team_name = $1
+	`, teamName, sla.ReminderMinutes, sla.ReassignMinutes)
+	if err != nil {
+		return fmt.Errorf("update team: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *TeamRepository) SetShadowFraction(ctx context.Context, teamName string, fraction float64) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+		UPDATE teams
+		SET shadow_fraction = $2
+		WHERE team_name = $1
+	`, teamName, fraction)
+	if err != nil {
+		return fmt.Errorf("update team: %w", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetUser returns the user with every team it belongs to in Teams and the
+// first of them, by name, in TeamName.
+func (r *TeamRepository) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+		SELECT user_id, username, is_active, role, email, timezone, working_hours
+		FROM users
 		WHERE user_id = $1
-		RETURNING user_id, username, team_name, is_active
-	`, userID, isActive)
+	`, userID)
 
 	var u domain.User
-	if err := row.Scan(&u.UserID, &u.Username, &u.TeamName, &u.IsActive); err != nil {
+	var hours []byte
+	if err := row.Scan(&u.UserID, &u.Username, &u.IsActive, &u.Role, &u.Email, &u.Timezone, &hours); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
 		}
-		return nil, fmt.Errorf("update: %w", err)
+		return nil, fmt.Errorf("select user: %w", err)
+	}
+	var err error
+	if u.WorkingHours, err = decodeWorkingHours(hours); err != nil {
+		return nil, err
+	}
+
+	teams, err := r.ListUserTeams(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	u.Teams = teams
+	if len(teams) > 0 {
+		u.TeamName = teams[0]
 	}
 	return &u, nil
 }
 
-func (r *TeamRepository) ListTeams(ctx context.Context) ([]domain.Team, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT team_name FROM teams`)
+func (r *TeamRepository) ListUserTeams(ctx context.Context, userID string) ([]string, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+		SELECT team_name
+		FROM team_members
+		WHERE user_id = $1
+		ORDER BY team_name
+	`, userID)
 	if err != nil {
-		return nil, fmt.Errorf("list teams: %w", err)
+		return nil, fmt.Errorf("list user teams: %w", err)
 	}
 	defer rows.Close()
 
-	var out []domain.Team
-
+	teams := make([]string, 0)
 	for rows.Next() {
 		var name string
 		if err := rows.Scan(&name); err != nil {
 			return nil, fmt.Errorf("scan team: %w", err)
 		}
+		teams = append(teams, name)
+	}
+	return teams, rows.Err()
+}
 
+func (r *TeamRepository) ListTeams(ctx context.Context) ([]domain.Team, error) {
+	// The names are read in full first: inside a transaction the connection
+	// cannot run GetTeam while the rows are still open.
+	names, err := r.ListTeamNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]domain.Team, 0, len(names))
+	for _, name := range names {
 		team, err := r.GetTeam(ctx, name)
 		if err != nil {
 			return nil, err
 		}
 		out = append(out, *team)
 	}
-
 	return out, nil
 }
 
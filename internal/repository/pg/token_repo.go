@@ -0,0 +1,100 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type TokenRepository struct {
+	db *sql.DB
+}
+
+func NewTokenRepository(db *sql.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (r *TokenRepository) CreateToken(ctx context.Context, t domain.APIToken, hash string) (*domain.APIToken, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+        INSERT INTO api_tokens (name, role, user_id, token_hash)
+        VALUES ($1, $2, NULLIF($3, ''), $4)
+        RETURNING id, created_at
+    `, t.Name, t.Role, t.UserID, hash)
+
+	if err := row.Scan(&t.ID, &t.CreatedAt); err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("insert token: %w", err)
+	}
+	return &t, nil
+}
+
+// GetTokenByHash returns the active token with the given hash, or nil when
+// there is none.
+func (r *TokenRepository) GetTokenByHash(ctx context.Context, hash string) (*domain.APIToken, error) {
+	row := r.db.QueryRowContext(ctx, `
+        SELECT id, name, role, COALESCE(user_id, ''), created_at
+        FROM api_tokens
+        WHERE token_hash = $1 AND revoked_at IS NULL
+    `, hash)
+
+	var t domain.APIToken
+	if err := row.Scan(&t.ID, &t.Name, &t.Role, &t.UserID, &t.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("select token: %w", err)
+	}
+	return &t, nil
+}
+
+func (r *TokenRepository) ListTokens(ctx context.Context) ([]domain.APIToken, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, name, role, COALESCE(user_id, ''), created_at, revoked_at
+        FROM api_tokens
+        ORDER BY id
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("list tokens: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.APIToken, 0)
+	for rows.Next() {
+		var t domain.APIToken
+		var revokedAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Name, &t.Role, &t.UserID, &t.CreatedAt, &revokedAt); err != nil {
+			return nil, fmt.Errorf("scan token: %w", err)
+		}
+		if revokedAt.Valid {
+			t.RevokedAt = &revokedAt.Time
+		}
+		list = append(list, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return list, nil
+}
+
+func (r *TokenRepository) RevokeToken(ctx context.Context, id int64) (*domain.APIToken, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+        UPDATE api_tokens
+        SET revoked_at = COALESCE(revoked_at, now())
+        WHERE id = $1
+        RETURNING id, name, role, COALESCE(user_id, ''), created_at, revoked_at
+    `, id)
+
+	var t domain.APIToken
+	if err := row.Scan(&t.ID, &t.Name, &t.Role, &t.UserID, &t.CreatedAt, &t.RevokedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("revoke token: %w", err)
+	}
+	return &t, nil
+}
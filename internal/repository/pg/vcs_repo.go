@@ -0,0 +1,243 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type VCSRepository struct {
+	db *sql.DB
+}
+
+func NewVCSRepository(db *sql.DB) *VCSRepository {
+	return &VCSRepository{db: db}
+}
+
+func (r *VCSRepository) SetIdentity(ctx context.Context, id domain.VCSIdentity) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO vcs_identities (provider, login, user_id)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (provider, login) DO UPDATE SET user_id = EXCLUDED.user_id
+    `, id.Provider, id.Login, id.UserID)
+
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrNotFound
+		}
+		return fmt.Errorf("set identity: %w", err)
+	}
+	return nil
+}
+
+func (r *VCSRepository) DeleteIdentity(ctx context.Context, provider domain.VCSProvider, login string) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+        DELETE FROM vcs_identities
+        WHERE provider = $1 AND login = $2
+    `, provider, login)
+	if err != nil {
+		return fmt.Errorf("delete identity: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete identity: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *VCSRepository) ListIdentities(ctx context.Context, provider domain.VCSProvider) ([]domain.VCSIdentity, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT provider, login, user_id
+        FROM vcs_identities
+        WHERE ($1 = '' OR provider = $1)
+        ORDER BY provider, login
+    `, provider)
+	if err != nil {
+		return nil, fmt.Errorf("list identities: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.VCSIdentity, 0)
+	for rows.Next() {
+		var id domain.VCSIdentity
+		if err := rows.Scan(&id.Provider, &id.Login, &id.UserID); err != nil {
+			return nil, fmt.Errorf("scan identity: %w", err)
+		}
+		list = append(list, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return list, nil
+}
+
+// ResolveIdentity returns the user mapped to login, or "" when there is none.
+func (r *VCSRepository) ResolveIdentity(ctx context.Context, provider domain.VCSProvider, login string) (string, error) {
+	var userID string
+	err := r.db.QueryRowContext(ctx, `
+        SELECT user_id
+        FROM vcs_identities
+        WHERE provider = $1 AND login = $2
+    `, provider, login).Scan(&userID)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", fmt.Errorf("resolve identity: %w", err)
+	}
+	return userID, nil
+}
+
+// RecordDelivery remembers a webhook delivery in the transaction carried by
+// ctx and reports whether it is new.
+func (r *VCSRepository) RecordDelivery(ctx context.Context, provider domain.VCSProvider, deliveryID string) (bool, error) {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO vcs_deliveries (provider, delivery_id)
+        VALUES ($1, $2)
+        ON CONFLICT DO NOTHING
+    `, provider, deliveryID)
+	if err != nil {
+		return false, fmt.Errorf("record delivery: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("record delivery: %w", err)
+	}
+	return n == 1, nil
+}
+
+// LoginsFor returns the provider logins of userIDs in the same order, skipping
+// users without one.
+func (r *VCSRepository) LoginsFor(ctx context.Context, provider domain.VCSProvider, userIDs []string) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT u.user_id, min(i.login)
+        FROM unnest($2::text[]) AS u(user_id)
+        JOIN vcs_identities i ON i.user_id = u.user_id AND i.provider = $1
+        GROUP BY u.user_id
+    `, provider, userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("logins for: %w", err)
+	}
+	defer rows.Close()
+
+	byUser := make(map[string]string, len(userIDs))
+	for rows.Next() {
+		var userID, login string
+		if err := rows.Scan(&userID, &login); err != nil {
+			return nil, fmt.Errorf("scan login: %w", err)
+		}
+		byUser[userID] = login
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	logins := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		if login, ok := byUser[id]; ok {
+			logins = append(logins, login)
+		}
+	}
+	return logins, nil
+}
+
+// QueuePush schedules a push of prID's reviewers. Call it inside the
+// transaction that changes them. A queued PR starts over: its attempts are
+// reset and its generation grows, so that a push claimed before the change
+// does not dequeue it.
+func (r *VCSRepository) QueuePush(ctx context.Context, prID string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO vcs_push_queue (pull_request_id)
+        VALUES ($1)
+        ON CONFLICT (pull_request_id) DO UPDATE
+        SET attempts = 0,
+            last_error = '',
+            next_attempt_at = now(),
+            failed_at = NULL,
+            generation = vcs_push_queue.generation + 1
+    `, prID)
+
+	if err != nil {
+		return fmt.Errorf("queue push: %w", err)
+	}
+	return nil
+}
+
+// ClaimPushes returns up to limit queued PRs whose push is due and pushes
+// their next attempt lease into the future, so that concurrent workers skip
+// them while they are being sent.
+func (r *VCSRepository) ClaimPushes(ctx context.Context, limit int, lease time.Duration) ([]domain.VCSPush, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        UPDATE vcs_push_queue
+        SET next_attempt_at = now() + make_interval(secs => $2)
+        WHERE pull_request_id IN (
+            SELECT pull_request_id FROM vcs_push_queue
+            WHERE failed_at IS NULL AND next_attempt_at <= now()
+            ORDER BY next_attempt_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING pull_request_id, attempts, last_error, next_attempt_at, generation
+    `, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim pushes: %w", err)
+	}
+	defer rows.Close()
+
+	var list []domain.VCSPush
+	for rows.Next() {
+		var p domain.VCSPush
+		if err := rows.Scan(&p.PullRequestID, &p.Attempts, &p.LastError, &p.NextAttemptAt, &p.Generation); err != nil {
+			return nil, fmt.Errorf("scan push: %w", err)
+		}
+		list = append(list, p)
+	}
+	return list, rows.Err()
+}
+
+// DeletePush dequeues a pushed PR unless it was queued again since the push
+// was claimed.
+func (r *VCSRepository) DeletePush(ctx context.Context, prID string, generation int64) error {
+	_, err := r.db.ExecContext(ctx, `
+        DELETE FROM vcs_push_queue
+        WHERE pull_request_id = $1 AND generation = $2
+    `, prID, generation)
+
+	if err != nil {
+		return fmt.Errorf("delete push: %w", err)
+	}
+	return nil
+}
+
+// RetryPush records a failed attempt. A zero next marks the push as failed
+// for good; it stays in the table for inspection. A PR queued again since the
+// push was claimed is left as queued.
+func (r *VCSRepository) RetryPush(ctx context.Context, prID string, generation int64, lastError string, next time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE vcs_push_queue
+        SET attempts = attempts + 1,
+            last_error = $3,
+            next_attempt_at = CASE WHEN $4::timestamptz IS NULL THEN next_attempt_at ELSE $4 END,
+            failed_at = CASE WHEN $4::timestamptz IS NULL THEN now() END
+        WHERE pull_request_id = $1 AND generation = $2
+    `, prID, generation, lastError, nullTime(next))
+
+	if err != nil {
+		return fmt.Errorf("retry push: %w", err)
+	}
+	return nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
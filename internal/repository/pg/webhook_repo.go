@@ -0,0 +1,259 @@
+package pg
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+const subscriptionColumns = `id, url, events, COALESCE(team_name, ''), secret, active, created_at`
+
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, s domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	events, err := json.Marshal(s.Events)
+	if err != nil {
+		return nil, fmt.Errorf("marshal events: %w", err)
+	}
+
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+        INSERT INTO webhook_subscriptions (url, events, team_name, secret, active)
+        VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+        RETURNING `+subscriptionColumns,
+		s.URL, events, s.TeamName, s.Secret, s.Active)
+	return scanSubscription(row)
+}
+
+func (r *WebhookRepository) UpdateSubscription(ctx context.Context, s domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	events, err := json.Marshal(s.Events)
+	if err != nil {
+		return nil, fmt.Errorf("marshal events: %w", err)
+	}
+
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+        UPDATE webhook_subscriptions
+        SET url = $2, events = $3, team_name = NULLIF($4, ''), secret = $5, active = $6
+        WHERE id = $1
+        RETURNING `+subscriptionColumns,
+		s.ID, s.URL, events, s.TeamName, s.Secret, s.Active)
+	return scanSubscription(row)
+}
+
+func (r *WebhookRepository) GetSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error) {
+	row := conn(ctx, r.db).QueryRowContext(ctx, `
+        SELECT `+subscriptionColumns+`
+        FROM webhook_subscriptions
+        WHERE id = $1
+    `, id)
+	return scanSubscription(row)
+}
+
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	res, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete subscription: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete subscription: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// ListSubscriptions returns all subscriptions, or only the active ones.
+func (r *WebhookRepository) ListSubscriptions(ctx context.Context, activeOnly bool) ([]domain.WebhookSubscription, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, `
+        SELECT `+subscriptionColumns+`
+        FROM webhook_subscriptions
+        WHERE active OR NOT $1
+        ORDER BY id
+    `, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("list subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]domain.WebhookSubscription, 0)
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return list, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSubscription(row rowScanner) (*domain.WebhookSubscription, error) {
+	var s domain.WebhookSubscription
+	var events []byte
+	err := row.Scan(&s.ID, &s.URL, &events, &s.TeamName, &s.Secret, &s.Active, &s.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scan subscription: %w", err)
+	}
+	if err := json.Unmarshal(events, &s.Events); err != nil {
+		return nil, fmt.Errorf("decode subscription %d: %w", s.ID, err)
+	}
+	return &s, nil
+}
+
+// AddDelivery queues payload for a subscription in the transaction carried by
+// ctx. An event already queued for the subscription is skipped.
+func (r *WebhookRepository) AddDelivery(ctx context.Context, subscriptionID, eventID int64, t domain.DomainEventType, payload []byte) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `
+        INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (subscription_id, event_id) DO NOTHING
+    `, subscriptionID, eventID, t, payload)
+
+	if err != nil {
+		return fmt.Errorf("insert delivery: %w", err)
+	}
+	return nil
+}
+
+// ClaimDeliveries returns up to limit due deliveries of active subscriptions
+// and pushes their next attempt lease into the future, so that concurrent
+// workers skip them while they are being sent. Deliveries of an inactive
+// subscription wait until it is active again.
+func (r *WebhookRepository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        UPDATE webhook_deliveries
+        SET next_attempt_at = now() + make_interval(secs => $2)
+        WHERE id IN (
+            SELECT d.id FROM webhook_deliveries d
+            JOIN webhook_subscriptions s ON s.id = d.subscription_id
+            WHERE d.status = 'PENDING' AND d.next_attempt_at <= now() AND s.active
+            ORDER BY d.id
+            LIMIT $1
+            FOR UPDATE OF d SKIP LOCKED
+        )
+        RETURNING `+deliveryColumns,
+		limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim deliveries: %w", err)
+	}
+	return scanDeliveries(rows)
+}
+
+func (r *WebhookRepository) MarkDeliveryDelivered(ctx context.Context, id int64, code int) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE webhook_deliveries
+        SET status = 'DELIVERED', delivered_at = now(), attempts = attempts + 1,
+            last_status_code = $2, last_error = ''
+        WHERE id = $1
+    `, id, code)
+
+	if err != nil {
+		return fmt.Errorf("mark delivered: %w", err)
+	}
+	return nil
+}
+
+// MarkDeliveryFailed records a failed attempt and schedules the next one at
+// next. A zero next gives the delivery up.
+func (r *WebhookRepository) MarkDeliveryFailed(ctx context.Context, id int64, code int, lastError string, next time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE webhook_deliveries
+        SET attempts = attempts + 1,
+            last_status_code = $2,
+            last_error = $3,
+            status = CASE WHEN $4::timestamptz IS NULL THEN 'FAILED' ELSE 'PENDING' END,
+            next_attempt_at = COALESCE($4, next_attempt_at)
+        WHERE id = $1
+    `, id, code, lastError, nullTime(next))
+
+	if err != nil {
+		return fmt.Errorf("mark failed: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the newest deliveries of a subscription first.
+func (r *WebhookRepository) ListDeliveries(ctx context.Context, subscriptionID int64, status domain.WebhookDeliveryStatus, limit int) ([]domain.WebhookDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT `+deliveryColumns+`
+        FROM webhook_deliveries
+        WHERE subscription_id = $1 AND ($2 = '' OR status = $2)
+        ORDER BY id DESC
+        LIMIT $3
+    `, subscriptionID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list deliveries: %w", err)
+	}
+	return scanDeliveries(rows)
+}
+
+// RedeliverDelivery schedules a delivery of the subscription to be sent again
+// with a fresh set of attempts.
+func (r *WebhookRepository) RedeliverDelivery(ctx context.Context, subscriptionID, id int64) error {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE webhook_deliveries
+        SET status = 'PENDING', attempts = 0, next_attempt_at = now()
+        WHERE id = $1 AND subscription_id = $2
+    `, id, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("redeliver: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("redeliver: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const deliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts,
+            last_status_code, last_error, next_attempt_at, created_at, delivered_at`
+
+func scanDeliveries(rows *sql.Rows) ([]domain.WebhookDelivery, error) {
+	defer rows.Close()
+
+	list := make([]domain.WebhookDelivery, 0)
+	for rows.Next() {
+		var d domain.WebhookDelivery
+		var payload []byte
+		var deliveredAt sql.NullTime
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status,
+			&d.Attempts, &d.LastStatusCode, &d.LastError, &d.NextAttemptAt, &d.CreatedAt, &deliveredAt); err != nil {
+			return nil, fmt.Errorf("scan delivery: %w", err)
+		}
+		d.Payload = payload
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		list = append(list, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+	return list, nil
+}
@@ -0,0 +1,49 @@
+package reqctx
+
+import (
+	"context"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type ctxKey int
+
+const (
+	actorKey ctxKey = iota
+	requestIDKey
+	tokenKey
+)
+
+const SystemActor = "system"
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor returns the caller recorded for the request, or SystemActor when the
+// call did not originate from an identified client.
+func Actor(ctx context.Context) string {
+	if v, ok := ctx.Value(actorKey).(string); ok && v != "" {
+		return v
+	}
+	return SystemActor
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+func RequestID(ctx context.Context) string {
+	v, _ := ctx.Value(requestIDKey).(string)
+	return v
+}
+
+func WithToken(ctx context.Context, t *domain.APIToken) context.Context {
+	return context.WithValue(ctx, tokenKey, t)
+}
+
+// Token returns the API token the request was authenticated with, or nil.
+func Token(ctx context.Context) *domain.APIToken {
+	t, _ := ctx.Value(tokenKey).(*domain.APIToken)
+	return t
+}
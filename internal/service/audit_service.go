@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+	"github.com/egoisthemain/pr-reviewer/internal/reqctx"
+)
+
+const (
+	AuditTeamCreate            = "team.create"
+	AuditTeamSetShadowFraction = "team.set_shadow_fraction"
+	AuditTeamRename            = "team.rename"
+	AuditTeamDelete            = "team.delete"
+	AuditTeamAddMember         = "team.add_member"
+	AuditTeamRemoveMember      = "team.remove_member"
+	AuditTeamSetParent         = "team.set_parent"
+	AuditTeamSetChatChannel    = "team.set_chat_channel"
+	AuditTeamSetReviewSLA      = "team.set_review_sla"
+	AuditTeamDeleteChatChannel = "team.delete_chat_channel"
+	AuditUserSetActive         = "user.set_active"
+	AuditUserSetRole           = "user.set_role"
+	AuditUserSetUsername       = "user.set_username"
+	AuditUserSetEmail          = "user.set_email"
+	AuditUserSetSchedule       = "user.set_schedule"
+	AuditUserMove              = "user.move"
+	AuditPRCreate              = "pr.create"
+	AuditPRMerge               = "pr.merge"
+	AuditPRClose               = "pr.close"
+	AuditPRReopen              = "pr.reopen"
+	AuditPRReassign            = "pr.reassign"
+	AuditPRAddReviewer         = "pr.add_reviewer"
+	AuditPRRemoveReviewer      = "pr.remove_reviewer"
+	AuditPRSubmitReview        = "pr.submit_review"
+	AuditPRRemind              = "pr.remind"
+	AuditExclusionAdd          = "exclusion.add"
+	AuditExclusionDelete       = "exclusion.delete"
+	AuditTokenCreate           = "token.create"
+	AuditTokenRevoke           = "token.revoke"
+	AuditVCSSetIdentity        = "vcs.set_identity"
+	AuditVCSDeleteIdentity     = "vcs.delete_identity"
+	AuditWebhookCreate         = "webhook.create"
+	AuditWebhookUpdate         = "webhook.update"
+	AuditWebhookDelete         = "webhook.delete"
+)
+
+const (
+	auditEntityTeam        = "team"
+	auditEntityUser        = "user"
+	auditEntityPR          = "pull_request"
+	auditEntityExclusion   = "reviewer_exclusion"
+	auditEntityToken       = "api_token"
+	auditEntityVCSIdentity = "vcs_identity"
+	auditEntityWebhook     = "webhook_subscription"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+type AuditRepo interface {
+	InsertEntry(ctx context.Context, e domain.AuditEntry) error
+	ListEntries(ctx context.Context, f domain.AuditFilter) ([]domain.AuditEntry, error)
+}
+
+type AuditService struct {
+	repo AuditRepo
+}
+
+func NewAuditService(repo AuditRepo) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+// Record stores a mutation together with the actor and request ID carried by
+// ctx. Call it inside the transaction that applies the change, so that the
+// change and its audit entry are committed together.
+func (s *AuditService) Record(ctx context.Context, action, entityType, entityID string, before, after any) error {
+	if s == nil {
+		return nil
+	}
+
+	e := domain.AuditEntry{
+		Actor:      reqctx.Actor(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     marshalAudit(before),
+		After:      marshalAudit(after),
+		RequestID:  reqctx.RequestID(ctx),
+	}
+
+	if err := s.repo.InsertEntry(ctx, e); err != nil {
+		return fmt.Errorf("audit %s %s/%s: %w", action, entityType, entityID, err)
+	}
+	return nil
+}
+
+func (s *AuditService) List(ctx context.Context, f domain.AuditFilter) ([]domain.AuditEntry, error) {
+	if f.Limit <= 0 {
+		f.Limit = defaultAuditLimit
+	}
+	if f.Limit > maxAuditLimit {
+		f.Limit = maxAuditLimit
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	return s.repo.ListEntries(ctx, f)
+}
+
+func marshalAudit(v any) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil || string(b) == "null" {
+		return nil
+	}
+	return b
+}
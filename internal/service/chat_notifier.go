@@ -0,0 +1,354 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+const (
+	chatTimeout        = 5 * time.Second
+	chatBatch          = 50
+	chatLease          = time.Minute
+	maxChatAttempts    = 10
+	maxChatDelay       = time.Hour
+	chatDigestLimit    = 50
+	defaultAssignedTpl = `{{range $i, $r := .Reviewers}}{{if $i}}, {{end}}@{{$r.Username}}{{end}}: ` +
+		`please review "{{.PR.PullRequestName}}" ({{.PR.PullRequestID}}) by {{.Author.Username}}` +
+		`{{if .OldReviewer}}, taking over from @{{.OldReviewer.Username}}{{end}}.`
+	defaultDeactivatedTpl = `@{{.User.Username}} was deactivated.` +
+		`{{if .OpenReviews}} Open reviews to reassign:{{range .OpenReviews}}` + "\n" +
+		`• "{{.PullRequestName}}" ({{.PullRequestID}}){{end}}{{else}} No open reviews.{{end}}`
+)
+
+type ChatRepo interface {
+	SetChatChannel(ctx context.Context, c domain.TeamChatChannel) (*domain.TeamChatChannel, error)
+	GetChatChannel(ctx context.Context, teamName string) (*domain.TeamChatChannel, error)
+	DeleteChatChannel(ctx context.Context, teamName string) error
+	AddChatDelivery(ctx context.Context, teamName string, eventID int64, text string) error
+	ClaimChatDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.ChatDelivery, error)
+	MarkChatDelivered(ctx context.Context, id int64) error
+	MarkChatFailed(ctx context.Context, id int64, lastError string, next time.Time) error
+}
+
+// chatAssignment is the data of the assignment template.
+type chatAssignment struct {
+	Team        string
+	PR          domain.PullRequest
+	Author      domain.User
+	Reviewers   []domain.User
+	OldReviewer *domain.User
+}
+
+// chatDeactivation is the data of the deactivation digest template.
+type chatDeactivation struct {
+	Team        string
+	User        domain.User
+	OpenReviews []domain.PullRequest
+}
+
+// ChatNotifier posts Slack-compatible incoming webhook messages to team
+// channels: one when reviewers are assigned by CreatePR or ReassignReviewer,
+// sent to each reviewer's teams, and a digest of open reviews when a user is
+// deactivated, sent to the user's teams. As an outbox sink it renders and
+// queues one message per channel; Run sends the queued messages, so a failing
+// channel is retried on its own.
+type ChatNotifier struct {
+	repo   ChatRepo
+	teams  TeamRepo
+	prs    PullRequestRepo
+	audit  *AuditService
+	tx     Transactor
+	client *http.Client
+}
+
+func NewChatNotifier(repo ChatRepo, teams TeamRepo, prs PullRequestRepo, audit *AuditService, tx Transactor, client *http.Client) *ChatNotifier {
+	if client == nil {
+		client = &http.Client{Timeout: chatTimeout}
+	}
+	return &ChatNotifier{repo: repo, teams: teams, prs: prs, audit: audit, tx: tx, client: client}
+}
+
+func (n *ChatNotifier) SetChannel(ctx context.Context, c domain.TeamChatChannel) (*domain.TeamChatChannel, error) {
+	u, err := url.Parse(c.WebhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidChatChannel
+	}
+	for _, tpl := range []string{c.AssignedTemplate, c.DeactivatedTemplate} {
+		if _, err := template.New("").Parse(tpl); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidChatChannel, err)
+		}
+	}
+	if _, err := n.teams.GetTeam(ctx, c.TeamName); err != nil {
+		return nil, err
+	}
+
+	before, err := n.repo.GetChatChannel(ctx, c.TeamName)
+	if err != nil {
+		return nil, err
+	}
+	var after *domain.TeamChatChannel
+	err = n.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if after, err = n.repo.SetChatChannel(ctx, c); err != nil {
+			return err
+		}
+		return n.audit.Record(ctx, AuditTeamSetChatChannel, auditEntityTeam, c.TeamName, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+func (n *ChatNotifier) GetChannel(ctx context.Context, teamName string) (*domain.TeamChatChannel, error) {
+	c, err := n.repo.GetChatChannel(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrChatChannelNotFound
+	}
+	return c, nil
+}
+
+func (n *ChatNotifier) DeleteChannel(ctx context.Context, teamName string) error {
+	before, err := n.GetChannel(ctx, teamName)
+	if err != nil {
+		return err
+	}
+	return n.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := n.repo.DeleteChatChannel(ctx, teamName); err != nil {
+			return err
+		}
+		return n.audit.Record(ctx, AuditTeamDeleteChatChannel, auditEntityTeam, teamName, before, nil)
+	})
+}
+
+// Test posts a sample assignment message to the team's channel.
+func (n *ChatNotifier) Test(ctx context.Context, teamName string) error {
+	c, err := n.GetChannel(ctx, teamName)
+	if err != nil {
+		return err
+	}
+	text, err := render(c.AssignedTemplate, defaultAssignedTpl, chatAssignment{
+		Team:      teamName,
+		PR:        domain.PullRequest{PullRequestID: "test", PullRequestName: "Test notification", Status: domain.PROpen},
+		Author:    domain.User{UserID: "pr-reviewer", Username: "pr-reviewer"},
+		Reviewers: []domain.User{{UserID: "reviewer", Username: "reviewer"}},
+	})
+	if err != nil {
+		return err
+	}
+	return n.post(ctx, c.WebhookURL, text)
+}
+
+func (n *ChatNotifier) Name() string { return "chat" }
+
+func (n *ChatNotifier) Deliver(ctx context.Context, e domain.OutboxEvent) error {
+	p := e.Payload
+	switch e.Type {
+	case domain.DomainPRCreated:
+		return n.assigned(ctx, e.ID, *p.PullRequest, p.PullRequest.AssignedReviewers, "")
+	case domain.DomainReviewerReassigned:
+		return n.assigned(ctx, e.ID, *p.PullRequest, []string{p.UserID}, p.OldUserID)
+	case domain.DomainUserDeactivated:
+		return n.deactivated(ctx, e.ID, *p.User)
+	}
+	return nil
+}
+
+func (n *ChatNotifier) assigned(ctx context.Context, eventID int64, pr domain.PullRequest, reviewerIDs []string, oldID string) error {
+	if len(reviewerIDs) == 0 {
+		return nil
+	}
+
+	author, err := n.teams.GetUser(ctx, pr.AuthorID)
+	if err != nil {
+		return err
+	}
+	var old *domain.User
+	if oldID != "" {
+		if old, err = n.teams.GetUser(ctx, oldID); err != nil {
+			return err
+		}
+	}
+
+	var teams []string
+	byTeam := make(map[string][]domain.User)
+	for _, id := range reviewerIDs {
+		u, err := n.teams.GetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, t := range u.Teams {
+			if _, ok := byTeam[t]; !ok {
+				teams = append(teams, t)
+			}
+			byTeam[t] = append(byTeam[t], *u)
+		}
+	}
+
+	for _, t := range teams {
+		c, err := n.repo.GetChatChannel(ctx, t)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			continue
+		}
+		text, err := render(c.AssignedTemplate, defaultAssignedTpl, chatAssignment{
+			Team: t, PR: pr, Author: *author, Reviewers: byTeam[t], OldReviewer: old,
+		})
+		if err != nil {
+			return fmt.Errorf("team %s: %w", t, err)
+		}
+		if err := n.repo.AddChatDelivery(ctx, t, eventID, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deactivated queues a digest of u's open reviews, shadow reviews left out,
+// for each of u's teams.
+func (n *ChatNotifier) deactivated(ctx context.Context, eventID int64, u domain.User) error {
+	var open []domain.PullRequest
+	for _, t := range u.Teams {
+		c, err := n.repo.GetChatChannel(ctx, t)
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			continue
+		}
+		if open == nil {
+			open, err = n.prs.ListPRs(ctx, domain.PRListFilter{
+				Status:       domain.PROpen,
+				ReviewerID:   u.UserID,
+				AssignedOnly: true,
+				Limit:        chatDigestLimit,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		text, err := render(c.DeactivatedTemplate, defaultDeactivatedTpl, chatDeactivation{
+			Team: t, User: u, OpenReviews: open,
+		})
+		if err != nil {
+			return fmt.Errorf("team %s: %w", t, err)
+		}
+		if err := n.repo.AddChatDelivery(ctx, t, eventID, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run sends queued messages every interval until ctx is done.
+func (n *ChatNotifier) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			for {
+				sent, err := n.sendDue(ctx)
+				if err != nil {
+					log.Printf("chat deliveries: %v", err)
+				}
+				if err != nil || sent < chatBatch {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (n *ChatNotifier) sendDue(ctx context.Context) (int, error) {
+	due, err := n.repo.ClaimChatDeliveries(ctx, chatBatch, chatLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, d := range due {
+		err := n.post(ctx, d.WebhookURL, d.Text)
+		if err == nil {
+			err = n.repo.MarkChatDelivered(ctx, d.ID)
+		} else {
+			var next time.Time
+			if d.Attempts+1 < maxChatAttempts {
+				next = time.Now().Add(chatDelay(d.Attempts + 1))
+			} else {
+				log.Printf("chat message %d to team %s failed: %v", d.ID, d.TeamName, err)
+			}
+			err = n.repo.MarkChatFailed(ctx, d.ID, err.Error(), next)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// render executes tpl, or fallback when it is empty, with data.
+func render(tpl, fallback string, data any) (string, error) {
+	if tpl == "" {
+		tpl = fallback
+	}
+	t, err := template.New("message").Parse(tpl)
+	if err != nil {
+		return "", err
+	}
+	var text bytes.Buffer
+	if err := t.Execute(&text, data); err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
+
+// post sends text as an incoming webhook message.
+func (n *ChatNotifier) post(ctx context.Context, webhookURL, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("chat webhook: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// chatDelay doubles from ten seconds up to maxChatDelay.
+func chatDelay(attempts int) time.Duration {
+	d := 10 * time.Second << attempts
+	if d <= 0 || d > maxChatDelay {
+		return maxChatDelay
+	}
+	return d
+}
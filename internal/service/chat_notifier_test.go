@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type memChatRepo struct {
+	ChatRepo
+	channels   map[string]*domain.TeamChatChannel
+	deliveries []chatRow
+}
+
+type chatRow struct {
+	domain.ChatDelivery
+	status string
+}
+
+func (r *memChatRepo) GetChatChannel(ctx context.Context, teamName string) (*domain.TeamChatChannel, error) {
+	return r.channels[teamName], nil
+}
+
+func (r *memChatRepo) AddChatDelivery(ctx context.Context, teamName string, eventID int64, text string) error {
+	for _, d := range r.deliveries {
+		if d.TeamName == teamName && d.EventID == eventID {
+			return nil
+		}
+	}
+	r.deliveries = append(r.deliveries, chatRow{
+		ChatDelivery: domain.ChatDelivery{ID: int64(len(r.deliveries) + 1), TeamName: teamName, EventID: eventID, Text: text},
+		status:       "PENDING",
+	})
+	return nil
+}
+
+func (r *memChatRepo) ClaimChatDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.ChatDelivery, error) {
+	var out []domain.ChatDelivery
+	for _, d := range r.deliveries {
+		if d.status == "PENDING" && len(out) < limit {
+			d.WebhookURL = r.channels[d.TeamName].WebhookURL
+			out = append(out, d.ChatDelivery)
+		}
+	}
+	return out, nil
+}
+
+func (r *memChatRepo) MarkChatDelivered(ctx context.Context, id int64) error {
+	r.deliveries[id-1].status = "DELIVERED"
+	r.deliveries[id-1].Attempts++
+	return nil
+}
+
+func (r *memChatRepo) MarkChatFailed(ctx context.Context, id int64, lastError string, next time.Time) error {
+	r.deliveries[id-1].Attempts++
+	if next.IsZero() {
+		r.deliveries[id-1].status = "FAILED"
+	}
+	return nil
+}
+
+type memUsers struct {
+	TeamRepo
+	users map[string]domain.User
+}
+
+func (r memUsers) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	u, ok := r.users[userID]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &u, nil
+}
+
+// memPRList answers ListPRs for a reviewer from prs.
+type memPRList struct {
+	PullRequestRepo
+	prs     []domain.PullRequest
+	filters []domain.PRListFilter
+}
+
+func (r *memPRList) ListPRs(ctx context.Context, f domain.PRListFilter) ([]domain.PullRequest, error) {
+	r.filters = append(r.filters, f)
+	var out []domain.PullRequest
+	for _, pr := range r.prs {
+		if slices.Contains(pr.AssignedReviewers, f.ReviewerID) ||
+			(!f.AssignedOnly && slices.Contains(pr.ShadowReviewers, f.ReviewerID)) {
+			out = append(out, pr)
+		}
+	}
+	return out, nil
+}
+
+// chatServer is an incoming webhook that answers with status and keeps the
+// texts it received.
+type chatServer struct {
+	mu     sync.Mutex
+	status int
+	texts  []string
+}
+
+func newChatServer(t *testing.T, status int) (*chatServer, string) {
+	t.Helper()
+	cs := &chatServer{status: status}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct {
+			Text string `json:"text"`
+		}
+		if r.Header.Get("Content-Type") != "application/json" || json.NewDecoder(r.Body).Decode(&msg) != nil {
+			http.Error(w, "bad message", http.StatusBadRequest)
+			return
+		}
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+		cs.texts = append(cs.texts, msg.Text)
+		w.WriteHeader(cs.status)
+	}))
+	t.Cleanup(srv.Close)
+	return cs, srv.URL
+}
+
+func (cs *chatServer) setStatus(status int) {
+	cs.mu.Lock()
+	cs.status = status
+	cs.mu.Unlock()
+}
+
+func (cs *chatServer) received() []string {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return append([]string(nil), cs.texts...)
+}
+
+var chatTestUsers = memUsers{users: map[string]domain.User{
+	"u1": {UserID: "u1", Username: "alice", Teams: []string{"backend"}},
+	"u2": {UserID: "u2", Username: "bob", Teams: []string{"backend"}},
+	"u3": {UserID: "u3", Username: "carol", Teams: []string{"frontend"}},
+}}
+
+func TestChatNotifierQueuesOneDeliveryPerChannel(t *testing.T) {
+	backend, backendURL := newChatServer(t, http.StatusInternalServerError)
+	frontend, frontendURL := newChatServer(t, http.StatusOK)
+	repo := &memChatRepo{channels: map[string]*domain.TeamChatChannel{
+		"backend":  {TeamName: "backend", WebhookURL: backendURL},
+		"frontend": {TeamName: "frontend", WebhookURL: frontendURL, AssignedTemplate: "{{.Team}}: {{range .Reviewers}}@{{.Username}}{{end}}"},
+	}}
+	n := NewChatNotifier(repo, chatTestUsers, &memPRList{}, nil, nil, nil)
+
+	pr := domain.PullRequest{PullRequestID: "pr1", PullRequestName: "Add cache", AuthorID: "u1",
+		AssignedReviewers: []string{"u2", "u3"}}
+	e := domain.OutboxEvent{ID: 7, Type: domain.DomainPRCreated, AggregateID: "pr1",
+		Payload: domain.EventPayload{PullRequest: &pr}}
+
+	// The sink only queues: a failing channel cannot fail the outbox event,
+	// and a repeated event is not queued twice.
+	for i := 0; i < 2; i++ {
+		if err := n.Deliver(context.Background(), e); err != nil {
+			t.Fatalf("Deliver(): %v", err)
+		}
+	}
+	if len(repo.deliveries) != 2 {
+		t.Fatalf("queued %d deliveries; want one per channel", len(repo.deliveries))
+	}
+	if len(backend.received())+len(frontend.received()) != 0 {
+		t.Fatal("Deliver() posted to a channel directly")
+	}
+
+	if _, err := n.sendDue(context.Background()); err != nil {
+		t.Fatalf("sendDue(): %v", err)
+	}
+	backend.setStatus(http.StatusOK)
+	if _, err := n.sendDue(context.Background()); err != nil {
+		t.Fatalf("sendDue(): %v", err)
+	}
+
+	if got := frontend.received(); len(got) != 1 || got[0] != "frontend: @carol" {
+		t.Fatalf("frontend received %q; want one custom message", got)
+	}
+	got := backend.received()
+	want := `@bob: please review "Add cache" (pr1) by alice.`
+	if len(got) != 2 || got[1] != want {
+		t.Fatalf("backend received %q; want a retry of %q", got, want)
+	}
+	for _, d := range repo.deliveries {
+		if d.status != "DELIVERED" {
+			t.Fatalf("delivery to %s is %s; want DELIVERED", d.TeamName, d.status)
+		}
+	}
+}
+
+func TestChatNotifierDigestSkipsShadowReviews(t *testing.T) {
+	backend, url := newChatServer(t, http.StatusOK)
+	repo := &memChatRepo{channels: map[string]*domain.TeamChatChannel{
+		"backend": {TeamName: "backend", WebhookURL: url},
+	}}
+	prs := &memPRList{prs: []domain.PullRequest{
+		{PullRequestID: "pr1", PullRequestName: "Add cache", AssignedReviewers: []string{"u2"}},
+		{PullRequestID: "pr2", PullRequestName: "Shadowed", ShadowReviewers: []string{"u2"}},
+	}}
+	n := NewChatNotifier(repo, chatTestUsers, prs, nil, nil, nil)
+
+	u := chatTestUsers.users["u2"]
+	e := domain.OutboxEvent{ID: 8, Type: domain.DomainUserDeactivated, AggregateID: "u2",
+		Payload: domain.EventPayload{User: &u}}
+	if err := n.Deliver(context.Background(), e); err != nil {
+		t.Fatalf("Deliver(): %v", err)
+	}
+	if _, err := n.sendDue(context.Background()); err != nil {
+		t.Fatalf("sendDue(): %v", err)
+	}
+
+	got := backend.received()
+	if len(got) != 1 || !strings.Contains(got[0], "pr1") || strings.Contains(got[0], "pr2") {
+		t.Fatalf("digest = %q; want pr1 without the shadow review pr2", got)
+	}
+	if f := prs.filters[0]; !f.AssignedOnly || f.Status != domain.PROpen {
+		t.Fatalf("ListPRs filter = %+v; want open PRs with AssignedOnly", f)
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+// Cursors are opaque to clients: base64 of the JSON-encoded position.
+
+func encodeCursor(c domain.PRCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*domain.PRCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c domain.PRCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.PullRequestID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	htmltemplate "html/template"
+	"log"
+	"text/template"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+	"github.com/egoisthemain/pr-reviewer/internal/mailer"
+)
+
+const (
+	emailBatch       = 50
+	emailLease       = 5 * time.Minute
+	maxEmailAttempts = 8
+	maxEmailDelay    = time.Hour
+)
+
+var emailTemplates = map[domain.DomainEventType]struct{ subject, text, html string }{
+	domain.DomainReviewerAssigned: {
+		subject: `Review requested: {{.PR.PullRequestName}}`,
+		text: `Hi {{.Reviewer.Username}},
+
+{{.Author.Username}} asked you to review "{{.PR.PullRequestName}}" ({{.PR.PullRequestID}}).
+`,
+		html: `<p>Hi {{.Reviewer.Username}},</p>
+<p>{{.Author.Username}} asked you to review <b>{{.PR.PullRequestName}}</b> ({{.PR.PullRequestID}}).</p>
+`,
+	},
+	domain.DomainReviewerReassigned: {
+		subject: `Review requested: {{.PR.PullRequestName}}`,
+		text: `Hi {{.Reviewer.Username}},
+
+You now review "{{.PR.PullRequestName}}" ({{.PR.PullRequestID}}) by {{.Author.Username}}` +
+			`{{if .OldReviewer}} instead of {{.OldReviewer.Username}}{{end}}.
+`,
+		html: `<p>Hi {{.Reviewer.Username}},</p>
+<p>You now review <b>{{.PR.PullRequestName}}</b> ({{.PR.PullRequestID}}) by {{.Author.Username}}` +
+			`{{if .OldReviewer}} instead of {{.OldReviewer.Username}}{{end}}.</p>
+`,
+	},
+	domain.DomainReviewReminder: {
+		subject: `Reminder: review {{.PR.PullRequestName}}`,
+		text: `Hi {{.Reviewer.Username}},
+
+"{{.PR.PullRequestName}}" ({{.PR.PullRequestID}}) by {{.Author.Username}} is still waiting for your review.
+It was opened {{.PR.CreatedAt.Format "2006-01-02 15:04 MST"}}.
+`,
+		html: `<p>Hi {{.Reviewer.Username}},</p>
+<p><b>{{.PR.PullRequestName}}</b> ({{.PR.PullRequestID}}) by {{.Author.Username}} is still waiting for your review.
+It was opened {{.PR.CreatedAt.Format "2006-01-02 15:04 MST"}}.</p>
+`,
+	},
+}
+
+// emailData is what the email templates are executed with.
+type emailData struct {
+	Reviewer    domain.User
+	Author      domain.User
+	PR          domain.PullRequest
+	OldReviewer *domain.User
+}
+
+type emailTemplate struct {
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+type EmailRepo interface {
+	QueueEmail(ctx context.Context, m domain.Email) error
+	ClaimEmails(ctx context.Context, limit int, lease time.Duration) ([]domain.Email, error)
+	MarkEmailSent(ctx context.Context, id int64) error
+	MarkEmailFailed(ctx context.Context, id int64, lastError string, next time.Time) error
+}
+
+// Mailer sends a batch of emails, returning one error per message.
+type Mailer interface {
+	SendBatch(ctx context.Context, msgs []mailer.Message) []error
+}
+
+// EmailNotifier mails reviewers when they are assigned or reminded. As an
+// outbox sink it renders and queues the emails; Run sends the queue in
+// batches.
+type EmailNotifier struct {
+	repo      EmailRepo
+	teams     TeamRepo
+	mailer    Mailer
+	templates map[domain.DomainEventType]emailTemplate
+}
+
+func NewEmailNotifier(repo EmailRepo, teams TeamRepo, m Mailer) *EmailNotifier {
+	templates := make(map[domain.DomainEventType]emailTemplate, len(emailTemplates))
+	for t, src := range emailTemplates {
+		templates[t] = emailTemplate{
+			subject: template.Must(template.New("subject").Parse(src.subject)),
+			text:    template.Must(template.New("text").Parse(src.text)),
+			html:    htmltemplate.Must(htmltemplate.New("html").Parse(src.html)),
+		}
+	}
+	return &EmailNotifier{repo: repo, teams: teams, mailer: m, templates: templates}
+}
+
+func (n *EmailNotifier) Name() string { return "email" }
+
+// Deliver queues an email to the reviewer an assignment or reminder is about.
+// Inactive reviewers and reviewers without an address are skipped.
+func (n *EmailNotifier) Deliver(ctx context.Context, e domain.OutboxEvent) error {
+	tpl, ok := n.templates[e.Type]
+	if !ok {
+		return nil
+	}
+	p := e.Payload
+
+	reviewer, err := n.teams.GetUser(ctx, p.UserID)
+	if err != nil {
+		return err
+	}
+	if reviewer.Email == "" || !reviewer.IsActive {
+		return nil
+	}
+
+	data := emailData{Reviewer: *reviewer, PR: *p.PullRequest}
+	author, err := n.teams.GetUser(ctx, p.PullRequest.AuthorID)
+	if err != nil {
+		return err
+	}
+	data.Author = *author
+	if p.OldUserID != "" {
+		if data.OldReviewer, err = n.teams.GetUser(ctx, p.OldUserID); err != nil {
+			return err
+		}
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tpl.subject.Execute(&subject, data); err != nil {
+		return err
+	}
+	if err := tpl.text.Execute(&text, data); err != nil {
+		return err
+	}
+	if err := tpl.html.Execute(&html, data); err != nil {
+		return err
+	}
+
+	return n.repo.QueueEmail(ctx, domain.Email{
+		EventID: e.ID,
+		To:      reviewer.Email,
+		Subject: subject.String(),
+		Text:    text.String(),
+		HTML:    html.String(),
+	})
+}
+
+// Run sends queued emails every interval until ctx is done.
+func (n *EmailNotifier) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			for {
+				sent, err := n.sendDue(ctx)
+				if err != nil {
+					log.Printf("email queue: %v", err)
+				}
+				if err != nil || sent < emailBatch {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (n *EmailNotifier) sendDue(ctx context.Context) (int, error) {
+	due, err := n.repo.ClaimEmails(ctx, emailBatch, emailLease)
+	if err != nil || len(due) == 0 {
+		return 0, err
+	}
+
+	msgs := make([]mailer.Message, len(due))
+	for i, m := range due {
+		msgs[i] = mailer.Message{To: m.To, Subject: m.Subject, Text: m.Text, HTML: m.HTML}
+	}
+	errs := n.mailer.SendBatch(ctx, msgs)
+
+	for i, m := range due {
+		if errs[i] == nil {
+			err = n.repo.MarkEmailSent(ctx, m.ID)
+		} else {
+			var next time.Time
+			if m.Attempts+1 < maxEmailAttempts {
+				next = time.Now().Add(emailDelay(m.Attempts + 1))
+			} else {
+				log.Printf("email %d to %s failed: %v", m.ID, m.To, errs[i])
+			}
+			err = n.repo.MarkEmailFailed(ctx, m.ID, errs[i].Error(), next)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// emailDelay doubles from thirty seconds up to maxEmailDelay.
+func emailDelay(attempts int) time.Duration {
+	d := 30 * time.Second << attempts
+	if d <= 0 || d > maxEmailDelay {
+		return maxEmailDelay
+	}
+	return d
+}
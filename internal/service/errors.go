@@ -0,0 +1,53 @@
+package service
+
+import "errors"
+
+// codeError carries a code from the OpenAPI spec, so err.Error() is what the
+// handlers return to the client.
+type codeError string
+
+func (e codeError) Error() string { return string(e) }
+
+var (
+	ErrPRMerged              error = codeError("PR_MERGED")
+	ErrPRClosed              error = codeError("PR_CLOSED")
+	ErrNotAssigned           error = codeError("NOT_ASSIGNED")
+	ErrNoCandidate           error = codeError("NO_CANDIDATE")
+	ErrAlreadyAssigned       error = codeError("ALREADY_ASSIGNED")
+	ErrTooManyReviewers      error = codeError("TOO_MANY_REVIEWERS")
+	ErrReviewerInactive      error = codeError("REVIEWER_INACTIVE")
+	ErrAuthorAsReviewer      error = codeError("AUTHOR_CANNOT_REVIEW")
+	ErrShadowAsReviewer      error = codeError("SHADOW_CANNOT_REVIEW")
+	ErrReviewerNotInTeam     error = codeError("REVIEWER_NOT_IN_TEAM")
+	ErrUserNotFound          error = codeError("USER_NOT_FOUND")
+	ErrReviewerExcluded      error = codeError("REVIEWER_EXCLUDED")
+	ErrInvalidExclusion      error = codeError("INVALID_EXCLUSION")
+	ErrInvalidRole           error = codeError("INVALID_ROLE")
+	ErrInvalidShadowFraction error = codeError("INVALID_SHADOW_FRACTION")
+	ErrInvalidTeamName       error = codeError("INVALID_TEAM_NAME")
+	ErrInvalidUser           error = codeError("INVALID_USER")
+	ErrInvalidWindow         error = codeError("INVALID_WINDOW")
+	ErrInvalidCursor         error = codeError("INVALID_CURSOR")
+	ErrInvalidStatus         error = codeError("INVALID_STATUS")
+	ErrInvalidSort           error = codeError("INVALID_SORT")
+	ErrInvalidDecision       error = codeError("INVALID_DECISION")
+	ErrInvalidToken          error = codeError("INVALID_TOKEN")
+	ErrUnauthorized          error = codeError("UNAUTHORIZED")
+	ErrForbidden             error = codeError("FORBIDDEN")
+	ErrInvalidIdentity       error = codeError("INVALID_IDENTITY")
+	ErrUnknownIdentity       error = codeError("UNKNOWN_IDENTITY")
+	ErrInvalidWebhook        error = codeError("INVALID_WEBHOOK")
+	ErrInvalidChatChannel    error = codeError("INVALID_CHAT_CHANNEL")
+	ErrChatChannelNotFound   error = codeError("CHAT_CHANNEL_NOT_FOUND")
+	ErrInvalidEmail          error = codeError("INVALID_EMAIL")
+	ErrInvalidSLA            error = codeError("INVALID_SLA")
+	ErrInvalidSchedule       error = codeError("INVALID_SCHEDULE")
+	ErrSameTeam              error = codeError("SAME_TEAM")
+)
+
+// IsCodeError reports whether err is a business rule violation rather than an
+// infrastructure failure.
+func IsCodeError(err error) bool {
+	var ce codeError
+	return errors.As(err, &ce)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+type ExclusionRepo interface {
+	AddExclusion(ctx context.Context, e domain.ReviewerExclusion) (*domain.ReviewerExclusion, error)
+	DeleteExclusion(ctx context.Context, id int64) (*domain.ReviewerExclusion, error)
+	ListExclusions(ctx context.Context, userID, teamName string) ([]domain.ReviewerExclusion, error)
+	ExcludedReviewers(ctx context.Context, authorID string) ([]string, error)
+}
+
+type ExclusionService struct {
+	repo     ExclusionRepo
+	teamRepo TeamRepo
+	audit    *AuditService
+	tx       Transactor
+}
+
+func NewExclusionService(repo ExclusionRepo, teamRepo TeamRepo, audit *AuditService, tx Transactor) *ExclusionService {
+	return &ExclusionService{repo: repo, teamRepo: teamRepo, audit: audit, tx: tx}
+}
+
+func (s *ExclusionService) Add(ctx context.Context, e domain.ReviewerExclusion) (*domain.ReviewerExclusion, error) {
+	if (e.AuthorID == "") == (e.TeamName == "") || e.ReviewerID == "" || e.AuthorID == e.ReviewerID {
+		return nil, ErrInvalidExclusion
+	}
+
+	if _, err := s.teamRepo.GetUser(ctx, e.ReviewerID); err != nil {
+		return nil, ErrUserNotFound
+	}
+	if e.AuthorID != "" {
+		if _, err := s.teamRepo.GetUser(ctx, e.AuthorID); err != nil {
+			return nil, ErrUserNotFound
+		}
+	}
+	if e.TeamName != "" {
+		if _, err := s.teamRepo.GetTeam(ctx, e.TeamName); err != nil {
+			return nil, err
+		}
+	}
+
+	var created *domain.ReviewerExclusion
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if created, err = s.repo.AddExclusion(ctx, e); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditExclusionAdd, auditEntityExclusion, created.ReviewerID, nil, created)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (s *ExclusionService) Delete(ctx context.Context, id int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		removed, err := s.repo.DeleteExclusion(ctx, id)
+		if err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditExclusionDelete, auditEntityExclusion, removed.ReviewerID, removed, nil)
+	})
+}
+
+func (s *ExclusionService) List(ctx context.Context, userID, teamName string) ([]domain.ReviewerExclusion, error) {
+	return s.repo.ListExclusions(ctx, userID, teamName)
+}
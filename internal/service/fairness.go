@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"sort"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+const (
+	DefaultMaxGini   = 0.3
+	DefaultTolerance = 0.5
+)
+
+// Fairness reports, per team, how evenly assignments in the window were
+// spread over active members. A team is Skewed when its Gini coefficient
+// exceeds maxGini; members above mean*(1+tolerance) are overloaded and those
+// below mean*(1-tolerance) underused.
+func (s *StatsService) Fairness(ctx context.Context, f domain.StatsFilter, maxGini, tolerance float64) ([]domain.TeamFairness, error) {
+	f, err := s.prepareFilter(ctx, f, false)
+	if err != nil {
+		return nil, err
+	}
+
+	loads, err := s.repo.AssignmentLoads(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []domain.TeamFairness
+	for start := 0; start < len(loads); {
+		end := start
+		for end < len(loads) && loads[end].TeamName == loads[start].TeamName {
+			end++
+		}
+		out = append(out, teamFairness(loads[start].TeamName, loads[start:end], maxGini, tolerance))
+		start = end
+	}
+
+	if out == nil {
+		out = make([]domain.TeamFairness, 0)
+	}
+	return out, nil
+}
+
+func teamFairness(team string, loads []domain.ReviewerLoad, maxGini, tolerance float64) domain.TeamFairness {
+	tf := domain.TeamFairness{
+		TeamName:      team,
+		ActiveMembers: len(loads),
+		Overloaded:    make([]domain.ReviewerLoad, 0),
+		Underused:     make([]domain.ReviewerLoad, 0),
+	}
+
+	counts := make([]int, len(loads))
+	for i, l := range loads {
+		counts[i] = l.Assignments
+		tf.TotalAssignments += l.Assignments
+	}
+	if tf.TotalAssignments == 0 {
+		return tf
+	}
+
+	tf.Mean = float64(tf.TotalAssignments) / float64(len(loads))
+	tf.Gini = gini(counts)
+	tf.Skewed = tf.Gini > maxGini
+
+	sort.Ints(counts)
+	if lo, hi := counts[0], counts[len(counts)-1]; lo > 0 {
+		ratio := float64(hi) / float64(lo)
+		tf.MaxMinRatio = &ratio
+	}
+
+	for _, l := range loads {
+		switch n := float64(l.Assignments); {
+		case n > tf.Mean*(1+tolerance):
+			tf.Overloaded = append(tf.Overloaded, l)
+		case n < tf.Mean*(1-tolerance):
+			tf.Underused = append(tf.Underused, l)
+		}
+	}
+	return tf
+}
+
+// gini returns the Gini coefficient of counts: 0 when everyone has the same
+// share, approaching 1 when one member has everything.
+func gini(counts []int) float64 {
+	sorted := append([]int(nil), counts...)
+	sort.Ints(sorted)
+
+	var sum, weighted float64
+	for i, c := range sorted {
+		sum += float64(c)
+		weighted += float64(i+1) * float64(c)
+	}
+	if sum == 0 {
+		return 0
+	}
+
+	n := float64(len(sorted))
+	return 2*weighted/(n*sum) - (n+1)/n
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+func TestGini(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts []int
+		want   float64
+	}{
+		{"equal loads", []int{4, 4, 4, 4}, 0},
+		{"nobody assigned", []int{0, 0, 0}, 0},
+		{"single member", []int{7}, 0},
+		{"one member has everything of two", []int{0, 10}, 0.5},
+		{"one member has everything of four", []int{0, 12, 0, 0}, 0.75},
+		{"one member has everything of five", []int{0, 0, 0, 0, 3}, 0.8},
+		{"uneven", []int{1, 2, 3}, 2.0 / 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gini(tt.counts); math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("gini(%v) = %v; want %v", tt.counts, got, tt.want)
+			}
+		})
+	}
+}
+
+func fairnessLoads(counts ...int) []domain.ReviewerLoad {
+	loads := make([]domain.ReviewerLoad, len(counts))
+	for i, c := range counts {
+		loads[i] = domain.ReviewerLoad{TeamName: "backend", UserID: string(rune('a' + i)), Assignments: c}
+	}
+	return loads
+}
+
+func userIDs(loads []domain.ReviewerLoad) []string {
+	ids := make([]string, 0, len(loads))
+	for _, l := range loads {
+		ids = append(ids, l.UserID)
+	}
+	return ids
+}
+
+func TestTeamFairness(t *testing.T) {
+	t.Run("equal loads", func(t *testing.T) {
+		tf := teamFairness("backend", fairnessLoads(3, 3, 3), DefaultMaxGini, DefaultTolerance)
+		if tf.Gini != 0 || tf.Skewed || tf.Mean != 3 || tf.TotalAssignments != 9 || tf.ActiveMembers != 3 {
+			t.Fatalf("fairness = %+v; want an even team", tf)
+		}
+		if tf.MaxMinRatio == nil || *tf.MaxMinRatio != 1 {
+			t.Fatalf("MaxMinRatio = %v; want 1", tf.MaxMinRatio)
+		}
+		if len(tf.Overloaded) != 0 || len(tf.Underused) != 0 {
+			t.Fatalf("overloaded %v, underused %v; want none", tf.Overloaded, tf.Underused)
+		}
+	})
+
+	t.Run("one member has everything", func(t *testing.T) {
+		tf := teamFairness("backend", fairnessLoads(0, 8, 0, 0), DefaultMaxGini, DefaultTolerance)
+		if math.Abs(tf.Gini-0.75) > 1e-9 || !tf.Skewed {
+			t.Fatalf("Gini = %v, Skewed = %v; want 0.75 and skewed", tf.Gini, tf.Skewed)
+		}
+		if tf.MaxMinRatio != nil {
+			t.Fatalf("MaxMinRatio = %v; want nil while a member has no assignments", *tf.MaxMinRatio)
+		}
+		if got := userIDs(tf.Overloaded); len(got) != 1 || got[0] != "b" {
+			t.Fatalf("overloaded = %v; want [b]", got)
+		}
+		if got := userIDs(tf.Underused); len(got) != 3 {
+			t.Fatalf("underused = %v; want the other three", got)
+		}
+	})
+
+	t.Run("max/min ratio", func(t *testing.T) {
+		tf := teamFairness("backend", fairnessLoads(2, 6, 4), DefaultMaxGini, DefaultTolerance)
+		if tf.MaxMinRatio == nil || *tf.MaxMinRatio != 3 {
+			t.Fatalf("MaxMinRatio = %v; want 3", tf.MaxMinRatio)
+		}
+		if tf.Skewed {
+			t.Fatalf("Gini = %v; want below %v", tf.Gini, DefaultMaxGini)
+		}
+	})
+
+	t.Run("tolerance bounds are exclusive", func(t *testing.T) {
+		// Mean 4: 6 is exactly mean*1.5 and 2 exactly mean*0.5.
+		tf := teamFairness("backend", fairnessLoads(2, 4, 6, 4), DefaultMaxGini, DefaultTolerance)
+		if len(tf.Overloaded) != 0 || len(tf.Underused) != 0 {
+			t.Fatalf("overloaded %v, underused %v; want none at the bounds", userIDs(tf.Overloaded), userIDs(tf.Underused))
+		}
+	})
+
+	t.Run("no assignments", func(t *testing.T) {
+		tf := teamFairness("backend", fairnessLoads(0, 0), DefaultMaxGini, DefaultTolerance)
+		if tf.Gini != 0 || tf.Skewed || tf.MaxMinRatio != nil || tf.Overloaded == nil || tf.Underused == nil {
+			t.Fatalf("fairness = %+v; want zero values with empty lists", tf)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+const (
+	outboxBatch       = 100
+	outboxLease       = 2 * time.Minute
+	maxOutboxAttempts = 12
+	maxOutboxDelay    = 30 * time.Minute
+	defaultOutboxList = 100
+	maxOutboxList     = 1000
+)
+
+// Transactor runs fn in a database transaction that repositories pick up from
+// ctx.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type OutboxRepo interface {
+	AddOutboxEvent(ctx context.Context, e domain.OutboxEvent) error
+	ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error)
+	MarkSinkDelivered(ctx context.Context, id int64, sink string) error
+	MarkOutboxDelivered(ctx context.Context, id int64) error
+	MarkOutboxFailed(ctx context.Context, id int64, lastError string, next time.Time) error
+	ListOutboxEvents(ctx context.Context, status domain.OutboxStatus, limit int) ([]domain.OutboxEvent, error)
+	RequeueOutboxEvent(ctx context.Context, id int64) error
+	DiscardOutboxEvent(ctx context.Context, id int64) error
+}
+
+// Sink receives domain events from the outbox. Delivery is at least once, so
+// a sink must tolerate seeing an event again. Deliver runs outside of any
+// transaction.
+type Sink interface {
+	Name() string
+	Deliver(ctx context.Context, e domain.OutboxEvent) error
+}
+
+// emit writes a domain event to the outbox. Call it inside the transaction of
+// the change the event describes.
+func emit(ctx context.Context, outbox OutboxRepo, t domain.DomainEventType, aggregateID string, p domain.EventPayload) error {
+	return outbox.AddOutboxEvent(ctx, domain.OutboxEvent{Type: t, AggregateID: aggregateID, Payload: p})
+}
+
+// OutboxDispatcher delivers outbox events to every sink. An event is retried
+// with a growing delay until all sinks accept it, each sink getting it until
+// it accepts it once, and after maxOutboxAttempts it becomes a dead letter.
+// Later events of the same aggregate wait for it, dead or not, until it is
+// requeued or discarded.
+type OutboxDispatcher struct {
+	repo  OutboxRepo
+	sinks []Sink
+}
+
+func NewOutboxDispatcher(repo OutboxRepo, sinks ...Sink) *OutboxDispatcher {
+	return &OutboxDispatcher{repo: repo, sinks: sinks}
+}
+
+// Run dispatches events every interval until ctx is done.
+func (d *OutboxDispatcher) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			for {
+				n, err := d.dispatch(ctx)
+				if err != nil {
+					log.Printf("outbox dispatch: %v", err)
+				}
+				if err != nil || n < outboxBatch {
+					break
+				}
+			}
+		}
+	}
+}
+
+// dispatch claims a batch of due events and delivers them. The claim is a
+// lease rather than a lock, so no transaction is held while sinks run.
+func (d *OutboxDispatcher) dispatch(ctx context.Context) (int, error) {
+	events, err := d.repo.ClaimOutboxEvents(ctx, outboxBatch, outboxLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range events {
+		if err := d.deliver(ctx, e); err != nil {
+			var next time.Time
+			if e.Attempts+1 < maxOutboxAttempts {
+				next = time.Now().Add(outboxDelay(e.Attempts + 1))
+			} else {
+				log.Printf("outbox event %d (%s) is dead: %v", e.ID, e.Type, err)
+			}
+			if err := d.repo.MarkOutboxFailed(ctx, e.ID, err.Error(), next); err != nil {
+				return len(events), err
+			}
+			continue
+		}
+		if err := d.repo.MarkOutboxDelivered(ctx, e.ID); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+// deliver hands e to every sink that has not accepted it yet and records each
+// one that does.
+func (d *OutboxDispatcher) deliver(ctx context.Context, e domain.OutboxEvent) error {
+	done := make(map[string]bool, len(e.DeliveredSinks))
+	for _, name := range e.DeliveredSinks {
+		done[name] = true
+	}
+
+	var errs []error
+	for _, s := range d.sinks {
+		if done[s.Name()] {
+			continue
+		}
+		if err := s.Deliver(ctx, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+			continue
+		}
+		if err := d.repo.MarkSinkDelivered(ctx, e.ID, s.Name()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// outboxDelay doubles from five seconds up to maxOutboxDelay.
+func outboxDelay(attempts int) time.Duration {
+	d := 5 * time.Second << attempts
+	if d <= 0 || d > maxOutboxDelay {
+		return maxOutboxDelay
+	}
+	return d
+}
+
+func (d *OutboxDispatcher) List(ctx context.Context, status domain.OutboxStatus, limit int) ([]domain.OutboxEvent, error) {
+	switch status {
+	case "", domain.OutboxPending, domain.OutboxDelivered, domain.OutboxDead, domain.OutboxDiscarded:
+	default:
+		return nil, ErrInvalidStatus
+	}
+	if limit <= 0 {
+		limit = defaultOutboxList
+	}
+	if limit > maxOutboxList {
+		limit = maxOutboxList
+	}
+	return d.repo.ListOutboxEvents(ctx, status, limit)
+}
+
+func (d *OutboxDispatcher) Requeue(ctx context.Context, id int64) error {
+	return d.repo.RequeueOutboxEvent(ctx, id)
+}
+
+func (d *OutboxDispatcher) Discard(ctx context.Context, id int64) error {
+	return d.repo.DiscardOutboxEvent(ctx, id)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+// memOutbox keeps outbox events in memory. Claimed events are returned once
+// per claim while pending, like a lease that has run out.
+type memOutbox struct {
+	OutboxRepo
+	events []domain.OutboxEvent
+	leases []time.Duration
+}
+
+func (o *memOutbox) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	o.leases = append(o.leases, lease)
+	var out []domain.OutboxEvent
+	for _, e := range o.events {
+		if e.Status == domain.OutboxPending && len(out) < limit {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (o *memOutbox) event(id int64) *domain.OutboxEvent {
+	for i := range o.events {
+		if o.events[i].ID == id {
+			return &o.events[i]
+		}
+	}
+	return nil
+}
+
+func (o *memOutbox) MarkSinkDelivered(ctx context.Context, id int64, sink string) error {
+	e := o.event(id)
+	e.DeliveredSinks = append(e.DeliveredSinks, sink)
+	return nil
+}
+
+func (o *memOutbox) MarkOutboxDelivered(ctx context.Context, id int64) error {
+	e := o.event(id)
+	e.Status = domain.OutboxDelivered
+	e.Attempts++
+	return nil
+}
+
+func (o *memOutbox) MarkOutboxFailed(ctx context.Context, id int64, lastError string, next time.Time) error {
+	e := o.event(id)
+	e.Attempts++
+	e.LastError = lastError
+	if next.IsZero() {
+		e.Status = domain.OutboxDead
+	}
+	return nil
+}
+
+type countingSink struct {
+	name  string
+	fails int
+	got   []int64
+}
+
+func (s *countingSink) Name() string { return s.name }
+
+func (s *countingSink) Deliver(ctx context.Context, e domain.OutboxEvent) error {
+	s.got = append(s.got, e.ID)
+	if s.fails > 0 {
+		s.fails--
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func TestOutboxDispatcherRetriesOnlyFailedSinks(t *testing.T) {
+	repo := &memOutbox{events: []domain.OutboxEvent{
+		{ID: 1, Type: domain.DomainPRCreated, AggregateID: "pr1", Status: domain.OutboxPending},
+	}}
+	webhooks := &countingSink{name: "webhooks"}
+	chat := &countingSink{name: "chat", fails: 1}
+	d := NewOutboxDispatcher(repo, webhooks, chat)
+
+	for i := 0; i < 2; i++ {
+		if _, err := d.dispatch(context.Background()); err != nil {
+			t.Fatalf("dispatch #%d: %v", i+1, err)
+		}
+	}
+
+	e := repo.events[0]
+	if e.Status != domain.OutboxDelivered || e.Attempts != 2 {
+		t.Fatalf("event = %+v; want DELIVERED after 2 attempts", e)
+	}
+	if len(webhooks.got) != 1 || len(chat.got) != 2 {
+		t.Fatalf("deliveries: webhooks %v, chat %v; want the accepted sink not to get the retry", webhooks.got, chat.got)
+	}
+	if repo.leases[0] != outboxLease {
+		t.Fatalf("claim lease = %v; want %v", repo.leases[0], outboxLease)
+	}
+}
+
+func TestOutboxDispatcherDeadLetter(t *testing.T) {
+	repo := &memOutbox{events: []domain.OutboxEvent{
+		{ID: 1, Type: domain.DomainPRMerged, AggregateID: "pr1", Status: domain.OutboxPending,
+			Attempts: maxOutboxAttempts - 1},
+	}}
+	sink := &countingSink{name: "webhooks", fails: 1}
+
+	if _, err := NewOutboxDispatcher(repo, sink).dispatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if e := repo.events[0]; e.Status != domain.OutboxDead || e.LastError != "webhooks: unavailable" {
+		t.Fatalf("event = %+v; want DEAD with the sink's error", e)
+	}
+}
@@ -4,51 +4,165 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"slices"
 	"time"
 
 	"github.com/egoisthemain/pr-reviewer/internal/domain"
+	"github.com/egoisthemain/pr-reviewer/internal/reqctx"
 )
 
 type PullRequestRepo interface {
 	CreatePR(ctx context.Context, pr domain.PullRequest) error
 	GetPR(ctx context.Context, prID string) (*domain.PullRequest, error)
+	GetPRForUpdate(ctx context.Context, prID string) (*domain.PullRequest, error)
 	AddReviewer(ctx context.Context, prID string, userID string) error
+	AddShadowReviewer(ctx context.Context, prID string, userID string) error
 	RemoveReviewer(ctx context.Context, prID string, userID string) error
 	ListReviewers(ctx context.Context, prID string) ([]string, error)
 	SetMerged(ctx context.Context, prID string) error
-	ListPRsByReviewer(ctx context.Context, userID string) ([]domain.PullRequest, error)
+	SetClosed(ctx context.Context, prID string) error
+	SetReopened(ctx context.Context, prID string) error
+	PRExists(ctx context.Context, prID string) (bool, error)
+	AddEvent(ctx context.Context, prID string, eventType domain.PREventType, userID string) error
+	SetDecision(ctx context.Context, prID, userID string, decision domain.ReviewDecision) error
+	ListReviewerDetails(ctx context.Context, prID string) ([]domain.PRReviewer, error)
+	ListPRs(ctx context.Context, f domain.PRListFilter) ([]domain.PullRequest, error)
 }
 
+const MaxReviewers = 2
+
+const (
+	defaultPRPageSize = 50
+	maxPRPageSize     = 200
+)
+
 type PRService struct {
 	prRepo   PullRequestRepo
 	teamRepo TeamRepo
+	exclRepo ExclusionRepo
+	audit    *AuditService
+	pusher   *ReviewerPusher
+	tx       Transactor
+	outbox   OutboxRepo
 }
 
-func NewPRService(prRepo PullRequestRepo, teamRepo TeamRepo) *PRService {
+func NewPRService(prRepo PullRequestRepo, teamRepo TeamRepo, exclRepo ExclusionRepo, audit *AuditService,
+	pusher *ReviewerPusher, tx Transactor, outbox OutboxRepo) *PRService {
 	return &PRService{
 		prRepo:   prRepo,
 		teamRepo: teamRepo,
+		exclRepo: exclRepo,
+		audit:    audit,
+		pusher:   pusher,
+		tx:       tx,
+		outbox:   outbox,
+	}
+}
+
+// excludedReviewers returns the set of users the exclusion rules forbid from
+// reviewing authorID's PRs.
+func (s *PRService) excludedReviewers(ctx context.Context, authorID string) (map[string]bool, error) {
+	ids, err := s.exclRepo.ExcludedReviewers(ctx, authorID)
+	if err != nil {
+		return nil, fmt.Errorf("load exclusions: %w", err)
+	}
+
+	out := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		out[id] = true
 	}
+	return out, nil
 }
 
-func (s *PRService) findUser(ctx context.Context, userID string) (*domain.User, string, error) {
-	teams, err := s.teamRepo.ListTeams(ctx)
+// findUser returns the user together with every team it is a member of. A
+// user outside of any team comes back with no teams.
+func (s *PRService) findUser(ctx context.Context, userID string) (*domain.User, []domain.Team, error) {
+	user, err := s.teamRepo.GetUser(ctx, userID)
 	if err != nil {
-		return nil, "", fmt.Errorf("list teams: %w", err)
+		return nil, nil, err
 	}
 
+	teams := make([]domain.Team, 0, len(user.Teams))
+	for _, name := range user.Teams {
+		t, err := s.teamRepo.GetTeam(ctx, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load team: %w", err)
+		}
+		teams = append(teams, *t)
+	}
+	return user, teams, nil
+}
+
+// mergeTeams builds one candidate pool out of several teams. Users listed in
+// more than one team appear once, and the pool uses the highest
+// ShadowFraction among the teams.
+func mergeTeams(teams []domain.Team) *domain.Team {
+	pool := &domain.Team{}
+	seen := make(map[string]bool)
+
 	for _, t := range teams {
+		if t.ShadowFraction > pool.ShadowFraction {
+			pool.ShadowFraction = t.ShadowFraction
+		}
 		for _, u := range t.Members {
-			if u.UserID == userID {
-				return &u, t.TeamName, nil
+			if seen[u.UserID] {
+				continue
 			}
+			seen[u.UserID] = true
+			pool.Members = append(pool.Members, u)
 		}
 	}
+	return pool
+}
+
+// sharedTeams returns the teams of a that b is also a member of.
+func sharedTeams(a, b []domain.Team) []domain.Team {
+	names := make(map[string]bool, len(b))
+	for _, t := range b {
+		names[t.TeamName] = true
+	}
 
-	return nil, "", fmt.Errorf("user not found")
+	var out []domain.Team
+	for _, t := range a {
+		if names[t.TeamName] {
+			out = append(out, t)
+		}
+	}
+	return out
 }
 
+// pickRandomReviewers picks n users at random, taking those inside their
+// working hours first.
 func (s *PRService) pickRandomReviewers(users []domain.User, n int) []domain.User {
+	if n <= 0 {
+		return nil
+	}
+	if len(users) <= n {
+		return users
+	}
+
+	working, away := splitByWorkingHours(users, time.Now())
+	picked := pickRandom(working, n)
+	return append(picked, pickRandom(away, n-len(picked))...)
+}
+
+// splitByWorkingHours separates the users inside their working hours at now
+// from the rest.
+func splitByWorkingHours(users []domain.User, now time.Time) (working, away []domain.User) {
+	for _, u := range users {
+		if u.InWorkingHours(now) {
+			working = append(working, u)
+		} else {
+			away = append(away, u)
+		}
+	}
+	return working, away
+}
+
+func pickRandom(users []domain.User, n int) []domain.User {
+	if n <= 0 {
+		return nil
+	}
 	if len(users) <= n {
 		return users
 	}
@@ -69,30 +183,66 @@ func (s *PRService) pickRandomReviewers(users []domain.User, n int) []domain.Use
 	return out
 }
 
-func (s *PRService) CreatePR(ctx context.Context, prID, name, authorID string) (*domain.PullRequest, error) {
+// CreatePR assigns the author's requested reviewers first and fills the
+// remaining slots up to MaxReviewers from the members of the author's teams,
+// escalating up the team tree while slots stay empty. The second return
+// value lists the reviewers picked automatically.
+func (s *PRService) CreatePR(ctx context.Context, prID, name, authorID string, requested []string) (*domain.PullRequest, []string, error) {
 
-	_, teamName, err := s.findUser(ctx, authorID)
+	_, authorTeams, err := s.findUser(ctx, authorID)
 	if err != nil {
-		return nil, fmt.Errorf("author not found: %w", err)
+		return nil, nil, fmt.Errorf("author not found: %w", err)
 	}
 
-	team, err := s.teamRepo.GetTeam(ctx, teamName)
+	excluded, err := s.excludedReviewers(ctx, authorID)
 	if err != nil {
-		return nil, fmt.Errorf("load team: %w", err)
+		return nil, nil, err
 	}
 
-	candidates := make([]domain.User, 0)
-	for _, u := range team.Members {
-		if u.UserID == authorID {
-			continue
+	requested, err = s.checkRequestedReviewers(ctx, authorID, excluded, requested)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	team := mergeTeams(authorTeams)
+
+	taken := make(map[string]bool, len(requested))
+	for _, id := range requested {
+		taken[id] = true
+	}
+
+	candidates := regularCandidates(team, authorID, taken, excluded)
+	selected := s.pickRandomReviewers(candidates, MaxReviewers-len(requested))
+	for _, u := range selected {
+		taken[u.UserID] = true
+	}
+
+	if len(requested)+len(selected) < MaxReviewers {
+		levels, err := s.ancestorLevels(ctx, authorTeams)
+		if err != nil {
+			return nil, nil, err
 		}
-		if !u.IsActive {
-			continue
+
+		for _, roots := range levels {
+			if len(requested)+len(selected) >= MaxReviewers {
+				break
+			}
+
+			pool, err := s.subtreePool(ctx, roots)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			more := s.pickRandomReviewers(regularCandidates(pool, authorID, taken, excluded),
+				MaxReviewers-len(requested)-len(selected))
+			for _, u := range more {
+				taken[u.UserID] = true
+			}
+			selected = append(selected, more...)
 		}
-		candidates = append(candidates, u)
 	}
 
-	selected := s.pickRandomReviewers(candidates, 2)
+	shadow := s.pickShadowReviewer(team, authorID, taken, excluded)
 
 	pr := domain.PullRequest{
 		PullRequestID:     prID,
@@ -103,16 +253,145 @@ func (s *PRService) CreatePR(ctx context.Context, prID, name, authorID string) (
 		AssignedReviewers: []string{},
 	}
 
-	if err := s.prRepo.CreatePR(ctx, pr); err != nil {
-		return nil, fmt.Errorf("create pr: %w", err)
+	auto := make([]string, 0, len(selected))
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.prRepo.CreatePR(ctx, pr); err != nil {
+			return fmt.Errorf("create pr: %w", err)
+		}
+
+		for _, id := range requested {
+			if err := s.assignReviewer(ctx, prID, id); err != nil {
+				return err
+			}
+			pr.AssignedReviewers = append(pr.AssignedReviewers, id)
+		}
+
+		for _, r := range selected {
+			if err := s.assignReviewer(ctx, prID, r.UserID); err != nil {
+				return err
+			}
+			pr.AssignedReviewers = append(pr.AssignedReviewers, r.UserID)
+			auto = append(auto, r.UserID)
+		}
+
+		if shadow != nil {
+			if err := s.prRepo.AddShadowReviewer(ctx, prID, shadow.UserID); err != nil {
+				return err
+			}
+			pr.ShadowReviewers = []string{shadow.UserID}
+		}
+
+		if err := emit(ctx, s.outbox, domain.DomainPRCreated, prID, domain.EventPayload{PullRequest: &pr}); err != nil {
+			return err
+		}
+		for _, id := range pr.AssignedReviewers {
+			if err := emit(ctx, s.outbox, domain.DomainReviewerAssigned, prID,
+				domain.EventPayload{PullRequest: &pr, UserID: id}); err != nil {
+				return err
+			}
+		}
+		if err := s.pusher.Queue(ctx, prID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditPRCreate, auditEntityPR, prID, nil, map[string]any{
+			"pull_request":            pr,
+			"requested_reviewers":     requested,
+			"auto_assigned_reviewers": auto,
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.pusher.Wake()
+	return &pr, auto, nil
+}
+
+// regularCandidates returns the active non-shadow members of team that may
+// review a PR by authorID and are not in taken yet.
+func regularCandidates(team *domain.Team, authorID string, taken, excluded map[string]bool) []domain.User {
+	candidates := make([]domain.User, 0)
+	for _, u := range team.Members {
+		if u.UserID == authorID {
+			continue
+		}
+		if !u.IsActive {
+			continue
+		}
+		if taken[u.UserID] || excluded[u.UserID] {
+			continue
+		}
+		if u.Role == domain.RoleShadow {
+			continue
+		}
+		candidates = append(candidates, u)
+	}
+	return candidates
+}
+
+// pickShadowReviewer returns a shadow member of team for ShadowFraction of the
+// calls, or nil. Shadows come on top of the regular reviewers and do not count
+// toward MaxReviewers.
+func (s *PRService) pickShadowReviewer(team *domain.Team, authorID string, taken, excluded map[string]bool) *domain.User {
+	if team.ShadowFraction <= 0 || rand.Float64() >= team.ShadowFraction {
+		return nil
+	}
+
+	shadows := make([]domain.User, 0)
+	for _, u := range team.Members {
+		if u.Role != domain.RoleShadow || !u.IsActive {
+			continue
+		}
+		if u.UserID == authorID || taken[u.UserID] || excluded[u.UserID] {
+			continue
+		}
+		shadows = append(shadows, u)
 	}
 
-	for _, r := range selected {
-		_ = s.prRepo.AddReviewer(ctx, prID, r.UserID)
-		pr.AssignedReviewers = append(pr.AssignedReviewers, r.UserID)
+	picked := s.pickRandomReviewers(shadows, 1)
+	if len(picked) == 0 {
+		return nil
 	}
+	return &picked[0]
+}
+
+// checkRequestedReviewers drops duplicates from ids and makes sure every
+// requested reviewer exists, is active, is not a shadow, is not the author and
+// is not excluded.
+func (s *PRService) checkRequestedReviewers(ctx context.Context, authorID string, excluded map[string]bool, ids []string) ([]string, error) {
+	out := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
 
-	return &pr, nil
+		if id == authorID {
+			return nil, ErrAuthorAsReviewer
+		}
+		if excluded[id] {
+			return nil, ErrReviewerExcluded
+		}
+
+		u, err := s.teamRepo.GetUser(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if !u.IsActive {
+			return nil, ErrReviewerInactive
+		}
+		if u.Role == domain.RoleShadow {
+			return nil, ErrShadowAsReviewer
+		}
+		out = append(out, id)
+	}
+
+	if len(out) > MaxReviewers {
+		return nil, ErrTooManyReviewers
+	}
+	return out, nil
 }
 
 func (s *PRService) MergePR(ctx context.Context, prID string) (*domain.PullRequest, error) {
@@ -124,73 +403,647 @@ func (s *PRService) MergePR(ctx context.Context, prID string) (*domain.PullReque
 	if pr.Status == domain.PRMerged {
 		return pr, nil
 	}
+	if pr.Status == domain.PRClosed {
+		return nil, ErrPRClosed
+	}
+
+	var merged *domain.PullRequest
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.prRepo.SetMerged(ctx, prID); err != nil {
+			return fmt.Errorf("merge pr: %w", err)
+		}
+		if err := s.prRepo.AddEvent(ctx, prID, domain.EventPRMerged, ""); err != nil {
+			return err
+		}
+
+		merged, err = s.prRepo.GetPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if err := emit(ctx, s.outbox, domain.DomainPRMerged, prID, domain.EventPayload{PullRequest: merged}); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditPRMerge, auditEntityPR, prID, pr, merged)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+func (s *PRService) Exists(ctx context.Context, prID string) (bool, error) {
+	return s.prRepo.PRExists(ctx, prID)
+}
+
+// ClosePR closes an open PR without merging it. Closing a closed PR is a no-op.
+func (s *PRService) ClosePR(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	pr, err := s.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch pr.Status {
+	case domain.PRClosed:
+		return pr, nil
+	case domain.PRMerged:
+		return nil, ErrPRMerged
+	}
+
+	var closed *domain.PullRequest
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.prRepo.SetClosed(ctx, prID); err != nil {
+			return err
+		}
+		if err := s.prRepo.AddEvent(ctx, prID, domain.EventPRClosed, ""); err != nil {
+			return err
+		}
 
-	if err := s.prRepo.SetMerged(ctx, prID); err != nil {
-		return nil, fmt.Errorf("merge pr: %w", err)
+		closed, err = s.prRepo.GetPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if err := emit(ctx, s.outbox, domain.DomainPRClosed, prID, domain.EventPayload{PullRequest: closed}); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditPRClose, auditEntityPR, prID, pr, closed)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return s.prRepo.GetPR(ctx, prID)
+	return closed, nil
 }
 
-func (s *PRService) ReassignReviewer(ctx context.Context, prID, oldUserID string) (string, error) {
+// ReopenPR opens a closed PR again with the reviewers it had. Reopening an
+// open PR is a no-op.
+func (s *PRService) ReopenPR(ctx context.Context, prID string) (*domain.PullRequest, error) {
 	pr, err := s.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch pr.Status {
+	case domain.PROpen:
+		return pr, nil
+	case domain.PRMerged:
+		return nil, ErrPRMerged
+	}
+
+	var reopened *domain.PullRequest
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.prRepo.SetReopened(ctx, prID); err != nil {
+			return err
+		}
+		if err := s.prRepo.AddEvent(ctx, prID, domain.EventPRReopened, ""); err != nil {
+			return err
+		}
+
+		reopened, err = s.prRepo.GetPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if err := emit(ctx, s.outbox, domain.DomainPRReopened, prID, domain.EventPayload{PullRequest: reopened}); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditPRReopen, auditEntityPR, prID, pr, reopened)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reopened, nil
+}
+
+// ReassignReviewer replaces oldUserID with newUserID, or with a random active
+// member of the teams the old reviewer shares with the author when newUserID
+// is empty. If those teams have nobody left, the search escalates to the
+// subtrees of their parent teams, one level at a time.
+func (s *PRService) ReassignReviewer(ctx context.Context, prID, oldUserID, newUserID string) (string, error) {
+	pr, err := s.getOpenPR(ctx, prID)
 	if err != nil {
 		return "", err
 	}
 
-	if pr.Status == domain.PRMerged {
-		return "", fmt.Errorf("PR_MERGED")
+	if err := checkPRAccess(ctx, pr); err != nil {
+		return "", err
 	}
 
-	found := false
-	for _, r := range pr.AssignedReviewers {
-		if r == oldUserID {
-			found = true
-			break
+	if !isAssigned(pr, oldUserID) {
+		return "", ErrNotAssigned
+	}
+
+	_, oldTeams, err := s.findUser(ctx, oldUserID)
+	if err != nil {
+		return "", err
+	}
+
+	_, authorTeams, err := s.findUser(ctx, pr.AuthorID)
+	if err != nil {
+		return "", fmt.Errorf("author not found: %w", err)
+	}
+
+	// The replacement comes from the teams the outgoing reviewer shares with
+	// the author, or from the outgoing reviewer's own teams when they share
+	// none, e.g. after a cross-team request.
+	pool := sharedTeams(oldTeams, authorTeams)
+	if len(pool) == 0 {
+		pool = oldTeams
+	}
+	team := mergeTeams(pool)
+
+	excluded, err := s.excludedReviewers(ctx, pr.AuthorID)
+	if err != nil {
+		return "", err
+	}
+
+	candidates := reassignCandidates(pr, team, excluded, oldUserID)
+	if newUserID == "" && len(candidates) == 0 {
+		levels, err := s.ancestorLevels(ctx, pool)
+		if err != nil {
+			return "", err
+		}
+
+		for _, roots := range levels {
+			escalated, err := s.subtreePool(ctx, roots)
+			if err != nil {
+				return "", err
+			}
+
+			candidates = reassignCandidates(pr, escalated, excluded, oldUserID)
+			if len(candidates) > 0 {
+				break
+			}
 		}
 	}
-	if !found {
-		return "", fmt.Errorf("NOT_ASSIGNED")
+
+	if newUserID == "" {
+		if len(candidates) == 0 {
+			return "", ErrNoCandidate
+		}
+		if working, _ := splitByWorkingHours(candidates, time.Now()); len(working) > 0 {
+			candidates = working
+		}
+
+		rand.Seed(time.Now().UnixNano())
+		newUserID = candidates[rand.Intn(len(candidates))].UserID
+	} else if err := checkReviewer(pr, team, excluded, newUserID); err != nil {
+		return "", err
 	}
 
-	_, teamName, err := s.findUser(ctx, oldUserID)
+	var after *domain.PullRequest
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		locked, err := s.lockOpenPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if !isAssigned(locked, oldUserID) {
+			return ErrNotAssigned
+		}
+		if isAssigned(locked, newUserID) {
+			return ErrAlreadyAssigned
+		}
+		pr = locked
+
+		if err := s.prRepo.RemoveReviewer(ctx, prID, oldUserID); err != nil {
+			return err
+		}
+		if err := s.prRepo.AddEvent(ctx, prID, domain.EventReviewerReassignedAway, oldUserID); err != nil {
+			return err
+		}
+		if err := s.assignReviewer(ctx, prID, newUserID); err != nil {
+			return err
+		}
+
+		after, err = s.prRepo.GetPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if err := emit(ctx, s.outbox, domain.DomainReviewerReassigned, prID,
+			domain.EventPayload{PullRequest: after, UserID: newUserID, OldUserID: oldUserID}); err != nil {
+			return err
+		}
+		if err := s.pusher.Queue(ctx, prID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditPRReassign, auditEntityPR, prID, pr, after)
+	})
 	if err != nil {
 		return "", err
 	}
+	s.pusher.Wake()
+	return newUserID, nil
+}
+
+// AddReviewer assigns a named reviewer from the author's teams on top of the
+// current ones, as long as the PR stays within MaxReviewers.
+func (s *PRService) AddReviewer(ctx context.Context, prID, userID string) (*domain.PullRequest, error) {
+	pr, err := s.getOpenPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pr.AssignedReviewers) >= MaxReviewers && !isAssigned(pr, userID) {
+		return nil, ErrTooManyReviewers
+	}
+
+	_, authorTeams, err := s.findUser(ctx, pr.AuthorID)
+	if err != nil {
+		return nil, fmt.Errorf("author not found: %w", err)
+	}
+	team := mergeTeams(authorTeams)
+
+	excluded, err := s.excludedReviewers(ctx, pr.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkReviewer(pr, team, excluded, userID); err != nil {
+		return nil, err
+	}
+
+	var after *domain.PullRequest
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		locked, err := s.lockOpenPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if isAssigned(locked, userID) {
+			return ErrAlreadyAssigned
+		}
+		if len(locked.AssignedReviewers) >= MaxReviewers {
+			return ErrTooManyReviewers
+		}
+		pr = locked
+
+		if err := s.assignReviewer(ctx, prID, userID); err != nil {
+			return err
+		}
+
+		after, err = s.prRepo.GetPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if err := emit(ctx, s.outbox, domain.DomainReviewerAssigned, prID,
+			domain.EventPayload{PullRequest: after, UserID: userID}); err != nil {
+			return err
+		}
+		if err := s.pusher.Queue(ctx, prID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditPRAddReviewer, auditEntityPR, prID, pr, after)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	team, err := s.teamRepo.GetTeam(ctx, teamName)
+	s.pusher.Wake()
+	return after, nil
+}
+
+func (s *PRService) RemoveReviewer(ctx context.Context, prID, userID string) (*domain.PullRequest, error) {
+	pr, err := s.getOpenPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAssigned(pr, userID) {
+		return nil, ErrNotAssigned
+	}
+
+	var after *domain.PullRequest
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		locked, err := s.lockOpenPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if !isAssigned(locked, userID) {
+			return ErrNotAssigned
+		}
+		pr = locked
+
+		if err := s.prRepo.RemoveReviewer(ctx, prID, userID); err != nil {
+			return err
+		}
+		if err := s.prRepo.AddEvent(ctx, prID, domain.EventReviewerRemoved, userID); err != nil {
+			return err
+		}
+
+		after, err = s.prRepo.GetPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if err := emit(ctx, s.outbox, domain.DomainReviewerRemoved, prID,
+			domain.EventPayload{PullRequest: after, UserID: userID}); err != nil {
+			return err
+		}
+		if err := s.pusher.Queue(ctx, prID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditPRRemoveReviewer, auditEntityPR, prID, pr, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.pusher.Wake()
+	return after, nil
+}
+
+// assignReviewer puts userID on the PR and records the assignment event.
+func (s *PRService) assignReviewer(ctx context.Context, prID, userID string) error {
+	if err := s.prRepo.AddReviewer(ctx, prID, userID); err != nil {
+		return err
+	}
+	return s.prRepo.AddEvent(ctx, prID, domain.EventReviewerAssigned, userID)
+}
+
+func (s *PRService) getOpenPR(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	pr, err := s.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	return pr, checkOpen(pr)
+}
+
+// lockOpenPR re-reads the PR inside the transaction carried by ctx and locks
+// it, so that checks made on an earlier read are repeated against the state
+// the reviewer change is applied to.
+func (s *PRService) lockOpenPR(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	pr, err := s.prRepo.GetPRForUpdate(ctx, prID)
 	if err != nil {
-		return "", fmt.Errorf("load team: %w", err)
+		return nil, err
 	}
+	return pr, checkOpen(pr)
+}
+
+func checkOpen(pr *domain.PullRequest) error {
+	switch pr.Status {
+	case domain.PRMerged:
+		return ErrPRMerged
+	case domain.PRClosed:
+		return ErrPRClosed
+	}
+	return nil
+}
+
+// checkPRAccess lets a user token act only on PRs its user authored or
+// reviews.
+func checkPRAccess(ctx context.Context, pr *domain.PullRequest) error {
+	t := reqctx.Token(ctx)
+	if t == nil || t.Role != domain.TokenUser {
+		return nil
+	}
+	if t.UserID == pr.AuthorID || isAssigned(pr, t.UserID) || slices.Contains(pr.ShadowReviewers, t.UserID) {
+		return nil
+	}
+	return ErrForbidden
+}
+
+func isAssigned(pr *domain.PullRequest, userID string) bool {
+	for _, r := range pr.AssignedReviewers {
+		if r == userID {
+			return true
+		}
+	}
+	return false
+}
 
+// reassignCandidates returns the members of team that may replace oldUserID
+// on pr.
+func reassignCandidates(pr *domain.PullRequest, team *domain.Team, excluded map[string]bool, oldUserID string) []domain.User {
 	candidates := make([]domain.User, 0)
 	for _, u := range team.Members {
-		if !u.IsActive {
+		if u.UserID == oldUserID || u.Role == domain.RoleShadow {
 			continue
 		}
-		if u.UserID == oldUserID {
+		if checkReviewer(pr, team, excluded, u.UserID) != nil {
 			continue
 		}
 		candidates = append(candidates, u)
 	}
+	return candidates
+}
 
-	if len(candidates) == 0 {
-		return "", fmt.Errorf("NO_CANDIDATE")
+// checkReviewer reports whether userID may be put on pr as a reviewer drawn
+// from team.
+func checkReviewer(pr *domain.PullRequest, team *domain.Team, excluded map[string]bool, userID string) error {
+	if userID == pr.AuthorID {
+		return ErrAuthorAsReviewer
+	}
+	if excluded[userID] {
+		return ErrReviewerExcluded
+	}
+	if isAssigned(pr, userID) {
+		return ErrAlreadyAssigned
+	}
+	for _, id := range pr.ShadowReviewers {
+		if id == userID {
+			return ErrAlreadyAssigned
+		}
 	}
 
-	rand.Seed(time.Now().UnixNano())
-	newR := candidates[rand.Intn(len(candidates))]
+	for _, u := range team.Members {
+		if u.UserID != userID {
+			continue
+		}
+		if !u.IsActive {
+			return ErrReviewerInactive
+		}
+		if u.Role == domain.RoleShadow {
+			return ErrShadowAsReviewer
+		}
+		return nil
+	}
+	return ErrReviewerNotInTeam
+}
+
+func (s *PRService) GetPR(ctx context.Context, prID string) (*domain.PRDetails, error) {
+	pr, err := s.prRepo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	reviewers, err := s.prRepo.ListReviewerDetails(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &domain.PRDetails{PullRequest: pr, Reviewers: reviewers}
 
-	_ = s.prRepo.RemoveReviewer(ctx, prID, oldUserID)
-	_ = s.prRepo.AddReviewer(ctx, prID, newR.UserID)
+	author, err := s.teamRepo.GetUser(ctx, pr.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+	d.AuthorTeams = author.Teams
 
-	return newR.UserID, nil
+	return d, nil
 }
 
-func (s *PRService) ListPRByReviewer(ctx context.Context, userID string) ([]domain.PullRequest, error) {
-	rows, err := s.prRepo.ListPRsByReviewer(ctx, userID)
+// SubmitReview records the decision of an assigned or shadow reviewer. A later
+// submission replaces the earlier one.
+func (s *PRService) SubmitReview(ctx context.Context, prID, userID string, decision domain.ReviewDecision) (*domain.PullRequest, error) {
+	if decision != domain.DecisionApproved && decision != domain.DecisionChangesRequested {
+		return nil, ErrInvalidDecision
+	}
+
+	pr, err := s.getOpenPR(ctx, prID)
 	if err != nil {
 		return nil, err
 	}
-	return rows, nil
+
+	if t := reqctx.Token(ctx); t != nil && t.Role == domain.TokenUser && t.UserID != userID {
+		return nil, ErrForbidden
+	}
+
+	if !isAssigned(pr, userID) && !slices.Contains(pr.ShadowReviewers, userID) {
+		return nil, ErrNotAssigned
+	}
+
+	var after *domain.PullRequest
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.prRepo.SetDecision(ctx, prID, userID, decision); err != nil {
+			return err
+		}
+		if err := s.prRepo.AddEvent(ctx, prID, domain.EventReviewSubmitted, userID); err != nil {
+			return err
+		}
+
+		after, err = s.prRepo.GetPR(ctx, prID)
+		if err != nil {
+			return err
+		}
+		if err := emit(ctx, s.outbox, domain.DomainReviewSubmitted, prID,
+			domain.EventPayload{PullRequest: after, UserID: userID, Decision: decision}); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditPRSubmitReview, auditEntityPR, prID, nil,
+			map[string]string{"user_id": userID, "decision": string(decision)})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+// RemindReviewers sends a reminder to every assigned reviewer of an open PR
+// who has not submitted a decision yet, and returns who was reminded.
+func (s *PRService) RemindReviewers(ctx context.Context, prID string) ([]string, error) {
+	pr, err := s.getOpenPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := s.prRepo.ListReviewerDetails(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	decided := make(map[string]bool, len(details))
+	for _, rv := range details {
+		decided[rv.UserID] = rv.Decision != ""
+	}
+
+	reminded := make([]string, 0, len(pr.AssignedReviewers))
+	for _, id := range pr.AssignedReviewers {
+		if !decided[id] {
+			reminded = append(reminded, id)
+		}
+	}
+	if len(reminded) == 0 {
+		return reminded, nil
+	}
+
+	if err := s.remind(ctx, pr, reminded); err != nil {
+		return nil, err
+	}
+	return reminded, nil
+}
+
+// RemindReviewer sends a reminder to one assigned reviewer of an open PR.
+func (s *PRService) RemindReviewer(ctx context.Context, prID, userID string) error {
+	pr, err := s.getOpenPR(ctx, prID)
+	if err != nil {
+		return err
+	}
+	if !isAssigned(pr, userID) {
+		return ErrNotAssigned
+	}
+	return s.remind(ctx, pr, []string{userID})
+}
+
+func (s *PRService) remind(ctx context.Context, pr *domain.PullRequest, userIDs []string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		for _, id := range userIDs {
+			if err := emit(ctx, s.outbox, domain.DomainReviewReminder, pr.PullRequestID,
+				domain.EventPayload{PullRequest: pr, UserID: id}); err != nil {
+				return err
+			}
+		}
+		return s.audit.Record(ctx, AuditPRRemind, auditEntityPR, pr.PullRequestID, nil, map[string][]string{"user_ids": userIDs})
+	})
+}
+
+// ListReviews returns one page of the PRs userID reviews, oldest first unless
+// f.Sort says otherwise. With f.PendingReview only open PRs still waiting for
+// the user's decision are returned.
+func (s *PRService) ListReviews(ctx context.Context, userID string, f domain.PRListFilter, cursor string) ([]domain.PullRequest, string, error) {
+	f.ReviewerID = userID
+	if f.PendingReview {
+		if f.Status != "" && f.Status != domain.PROpen {
+			return nil, "", ErrInvalidStatus
+		}
+		f.Status = domain.PROpen
+	}
+	if f.Sort == "" {
+		f.Sort = domain.SortCreatedAsc
+	}
+	return s.ListPRs(ctx, f, cursor)
+}
+
+// ListPRs returns one page of PRs matching f and the cursor of the next page,
+// which is empty on the last one.
+func (s *PRService) ListPRs(ctx context.Context, f domain.PRListFilter, cursor string) ([]domain.PullRequest, string, error) {
+	switch f.Status {
+	case "", domain.PROpen, domain.PRMerged, domain.PRClosed:
+	default:
+		return nil, "", ErrInvalidStatus
+	}
+
+	switch f.Sort {
+	case "":
+		f.Sort = domain.SortCreatedDesc
+	case domain.SortCreatedAsc, domain.SortCreatedDesc, domain.SortIDAsc, domain.SortIDDesc:
+	default:
+		return nil, "", ErrInvalidSort
+	}
+
+	if cursor != "" {
+		after, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		f.After = after
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultPRPageSize
+	}
+	if limit > maxPRPageSize {
+		limit = maxPRPageSize
+	}
+	f.Limit = limit + 1
+
+	prs, err := s.prRepo.ListPRs(ctx, f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(prs) <= limit {
+		return prs, "", nil
+	}
+
+	prs = prs[:limit]
+	last := prs[len(prs)-1]
+	return prs, encodeCursor(domain.PRCursor{CreatedAt: last.CreatedAt, PullRequestID: last.PullRequestID}), nil
 }
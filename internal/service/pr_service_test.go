@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+// noTx runs fn directly, as if it were the whole transaction.
+type noTx struct{}
+
+func (noTx) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error { return fn(ctx) }
+
+// racedPRRepo answers GetPR with the PR as first read and GetPRForUpdate
+// with the PR as changed by a concurrent request in the meantime.
+type racedPRRepo struct {
+	PullRequestRepo
+	read, locked domain.PullRequest
+	writes       []string
+}
+
+func (r *racedPRRepo) GetPR(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	pr := r.read
+	return &pr, nil
+}
+
+func (r *racedPRRepo) GetPRForUpdate(ctx context.Context, prID string) (*domain.PullRequest, error) {
+	pr := r.locked
+	return &pr, nil
+}
+
+func (r *racedPRRepo) AddReviewer(ctx context.Context, prID, userID string) error {
+	r.writes = append(r.writes, "add "+userID)
+	return nil
+}
+
+func (r *racedPRRepo) RemoveReviewer(ctx context.Context, prID, userID string) error {
+	r.writes = append(r.writes, "remove "+userID)
+	return nil
+}
+
+type memTeams struct {
+	TeamRepo
+	team domain.Team
+}
+
+func (r memTeams) GetUser(ctx context.Context, userID string) (*domain.User, error) {
+	for _, u := range r.team.Members {
+		if u.UserID == userID {
+			u.Teams = []string{r.team.TeamName}
+			return &u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func (r memTeams) GetTeam(ctx context.Context, teamName string) (*domain.Team, error) {
+	t := r.team
+	return &t, nil
+}
+
+type noExclusions struct{ ExclusionRepo }
+
+func (noExclusions) ExcludedReviewers(ctx context.Context, authorID string) ([]string, error) {
+	return nil, nil
+}
+
+func TestReviewerChangesRecheckLockedPR(t *testing.T) {
+	team := domain.Team{TeamName: "backend", Members: []domain.User{
+		{UserID: "u1", Username: "alice", IsActive: true},
+		{UserID: "u2", Username: "bob", IsActive: true},
+		{UserID: "u3", Username: "carol", IsActive: true},
+		{UserID: "u4", Username: "dave", IsActive: true},
+	}}
+	read := domain.PullRequest{PullRequestID: "pr1", AuthorID: "u1", Status: domain.PROpen,
+		AssignedReviewers: []string{"u2"}}
+
+	tests := []struct {
+		name   string
+		locked []string
+		change func(s *PRService) error
+		want   error
+	}{
+		{
+			name:   "add past the cap",
+			locked: []string{"u2", "u3"},
+			change: func(s *PRService) error {
+				_, err := s.AddReviewer(context.Background(), "pr1", "u4")
+				return err
+			},
+			want: ErrTooManyReviewers,
+		},
+		{
+			name:   "reassign a reviewer already replaced",
+			locked: []string{"u3"},
+			change: func(s *PRService) error {
+				_, err := s.ReassignReviewer(context.Background(), "pr1", "u2", "u4")
+				return err
+			},
+			want: ErrNotAssigned,
+		},
+		{
+			name:   "reassign to a reviewer assigned meanwhile",
+			locked: []string{"u2", "u4"},
+			change: func(s *PRService) error {
+				_, err := s.ReassignReviewer(context.Background(), "pr1", "u2", "u4")
+				return err
+			},
+			want: ErrAlreadyAssigned,
+		},
+		{
+			name:   "remove a reviewer removed meanwhile",
+			locked: nil,
+			change: func(s *PRService) error {
+				_, err := s.RemoveReviewer(context.Background(), "pr1", "u2")
+				return err
+			},
+			want: ErrNotAssigned,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locked := read
+			locked.AssignedReviewers = tt.locked
+			repo := &racedPRRepo{read: read, locked: locked}
+			s := NewPRService(repo, memTeams{team: team}, noExclusions{}, nil, nil, noTx{}, nil)
+
+			if err := tt.change(s); !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v; want %v", err, tt.want)
+			}
+			if len(repo.writes) != 0 {
+				t.Fatalf("writes = %v; want none after the locked re-check fails", repo.writes)
+			}
+		})
+	}
+}
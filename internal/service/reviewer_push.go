@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+	"github.com/egoisthemain/pr-reviewer/internal/vcs"
+)
+
+const (
+	pushTimeout     = time.Minute
+	pushLease       = 2 * pushTimeout
+	pushBatch       = 50
+	maxPushAttempts = 10
+	maxPushDelay    = time.Hour
+)
+
+type PushQueueRepo interface {
+	LoginsFor(ctx context.Context, provider domain.VCSProvider, userIDs []string) ([]string, error)
+	QueuePush(ctx context.Context, prID string) error
+	ClaimPushes(ctx context.Context, limit int, lease time.Duration) ([]domain.VCSPush, error)
+	DeletePush(ctx context.Context, prID string, generation int64) error
+	RetryPush(ctx context.Context, prID string, generation int64, lastError string, next time.Time) error
+}
+
+// ReviewerPusher sends the reviewers of PRs that came from a VCS webhook back
+// to the provider. Changes are queued in the transaction that makes them and
+// pushed by Run; a push that fails after the client's own retries is retried
+// with a growing delay.
+type ReviewerPusher struct {
+	repo    PushQueueRepo
+	prRepo  PullRequestRepo
+	clients map[domain.VCSProvider]vcs.Client
+	wake    chan struct{}
+}
+
+func NewReviewerPusher(repo PushQueueRepo, prRepo PullRequestRepo, clients map[domain.VCSProvider]vcs.Client) *ReviewerPusher {
+	return &ReviewerPusher{repo: repo, prRepo: prRepo, clients: clients, wake: make(chan struct{}, 1)}
+}
+
+// Queue schedules a push of prID's reviewers. Call it inside the transaction
+// that changes them, and Wake once it is committed. PRs that did not come
+// from a configured provider are skipped.
+func (p *ReviewerPusher) Queue(ctx context.Context, prID string) error {
+	if p == nil {
+		return nil
+	}
+	provider, _, _, ok := ParseVCSPullRequestID(prID)
+	if !ok || p.clients[provider] == nil {
+		return nil
+	}
+	return p.repo.QueuePush(ctx, prID)
+}
+
+// Wake makes Run push the queue now instead of at its next tick.
+func (p *ReviewerPusher) Wake() {
+	if p == nil {
+		return
+	}
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run pushes queued PRs every interval and on Wake until ctx is done.
+func (p *ReviewerPusher) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-p.wake:
+		}
+		p.pushDue(ctx)
+	}
+}
+
+func (p *ReviewerPusher) pushDue(ctx context.Context) {
+	due, err := p.repo.ClaimPushes(ctx, pushBatch, pushLease)
+	if err != nil {
+		log.Printf("claim queued pushes: %v", err)
+		return
+	}
+
+	for _, q := range due {
+		pctx, cancel := context.WithTimeout(ctx, pushTimeout)
+		err := p.push(pctx, q.PullRequestID)
+		cancel()
+
+		if err == nil {
+			if err := p.repo.DeletePush(ctx, q.PullRequestID, q.Generation); err != nil {
+				log.Printf("dequeue push of %s: %v", q.PullRequestID, err)
+			}
+			continue
+		}
+
+		log.Printf("push reviewers of %s: %v", q.PullRequestID, err)
+		var next time.Time
+		if !vcs.IsPermanent(err) && q.Attempts+1 < maxPushAttempts {
+			next = time.Now().Add(pushDelay(q.Attempts + 1))
+		}
+		if err := p.repo.RetryPush(ctx, q.PullRequestID, q.Generation, err.Error(), next); err != nil {
+			log.Printf("requeue push of %s: %v", q.PullRequestID, err)
+		}
+	}
+}
+
+func (p *ReviewerPusher) push(ctx context.Context, prID string) error {
+	provider, repository, number, ok := ParseVCSPullRequestID(prID)
+	if !ok {
+		return nil
+	}
+	client := p.clients[provider]
+	if client == nil {
+		return nil
+	}
+
+	reviewers, err := p.prRepo.ListReviewers(ctx, prID)
+	if err != nil {
+		return err
+	}
+	logins, err := p.repo.LoginsFor(ctx, provider, reviewers)
+	if err != nil {
+		return err
+	}
+
+	return client.RequestReviewers(ctx, repository, number, logins)
+}
+
+// pushDelay doubles from one minute up to maxPushDelay.
+func pushDelay(attempts int) time.Duration {
+	d := time.Minute << attempts
+	if d <= 0 || d > maxPushDelay {
+		return maxPushDelay
+	}
+	return d
+}
+
+// ParseVCSPullRequestID is the reverse of VCSPullRequestID.
+func ParseVCSPullRequestID(prID string) (domain.VCSProvider, string, int, bool) {
+	provider, rest, ok := strings.Cut(prID, ":")
+	if !ok {
+		return "", "", 0, false
+	}
+	i := strings.LastIndex(rest, "#")
+	if i <= 0 {
+		return "", "", 0, false
+	}
+	number, err := strconv.Atoi(rest[i+1:])
+	if err != nil {
+		return "", "", 0, false
+	}
+
+	p := domain.VCSProvider(provider)
+	if checkProvider(p) != nil {
+		return "", "", 0, false
+	}
+	return p, rest[:i], number, true
+}
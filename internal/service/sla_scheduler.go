@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+	"github.com/egoisthemain/pr-reviewer/internal/reqctx"
+)
+
+const (
+	slaActor          = "sla-scheduler"
+	defaultSLAActions = 100
+	maxSLAActions     = 1000
+)
+
+// errSLAActionExists rolls back a reassignment that another run has already
+// recorded for the same assignment.
+var errSLAActionExists = errors.New("sla action already recorded")
+
+type SLARepo interface {
+	ListPendingReviews(ctx context.Context) ([]domain.PendingReview, error)
+	AddSLAAction(ctx context.Context, a domain.SLAAction) (bool, error)
+	ListSLAActions(ctx context.Context, prID string, limit int) ([]domain.SLAAction, error)
+}
+
+// Leader reports whether this replica may run singleton background work.
+type Leader interface {
+	Acquire(ctx context.Context) (bool, error)
+	Release(ctx context.Context)
+}
+
+// SLAScheduler enforces the review SLA of teams: a reviewer who has not
+// decided within the reminder threshold gets one reminder, and after the
+// reassignment threshold is replaced through PRService.ReassignReviewer.
+// Thresholds count only the reviewer's working hours. Each
+// step is taken at most once per assignment and recorded in sla_actions.
+// Only the replica holding the leader lock does the work.
+type SLAScheduler struct {
+	repo   SLARepo
+	prs    *PRService
+	tx     Transactor
+	leader Leader
+}
+
+func NewSLAScheduler(repo SLARepo, prs *PRService, tx Transactor, leader Leader) *SLAScheduler {
+	return &SLAScheduler{repo: repo, prs: prs, tx: tx, leader: leader}
+}
+
+// Run checks the SLA every interval until ctx is done.
+func (s *SLAScheduler) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	defer s.leader.Release(context.Background())
+
+	ctx = reqctx.WithActor(ctx, slaActor)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			if err := s.check(ctx); err != nil {
+				log.Printf("sla scheduler: %v", err)
+			}
+		}
+	}
+}
+
+func (s *SLAScheduler) check(ctx context.Context) error {
+	leader, err := s.leader.Acquire(ctx)
+	if err != nil || !leader {
+		return err
+	}
+
+	pending, err := s.repo.ListPendingReviews(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, p := range pending {
+		elapsed := p.Schedule.WorkingTime(p.AssignedAt, now)
+		switch {
+		case p.SLA.ReassignMinutes > 0 && !p.Escalated && elapsed >= minutes(p.SLA.ReassignMinutes):
+			err = s.reassign(ctx, p)
+		case p.SLA.ReminderMinutes > 0 && !p.Reminded && elapsed >= minutes(p.SLA.ReminderMinutes):
+			err = s.remind(ctx, p)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("sla %s for %s: %v", p.PullRequestID, p.UserID, err)
+		}
+	}
+	return nil
+}
+
+// remind records the reminder and emits it in one transaction, so that it is
+// sent exactly once per assignment.
+func (s *SLAScheduler) remind(ctx context.Context, p domain.PendingReview) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		added, err := s.repo.AddSLAAction(ctx, domain.SLAAction{
+			PullRequestID: p.PullRequestID,
+			UserID:        p.UserID,
+			Action:        domain.SLAReminder,
+			AssignedAt:    p.AssignedAt,
+		})
+		if err != nil || !added {
+			return err
+		}
+		return s.prs.RemindReviewer(ctx, p.PullRequestID, p.UserID)
+	})
+}
+
+// reassign replaces the reviewer by the usual reassignment rules and records
+// the action in the same transaction, so that the reviewer is replaced at most
+// once per assignment. A rejected reassignment, e.g. for lack of candidates,
+// is recorded with its error and not retried for this assignment.
+func (s *SLAScheduler) reassign(ctx context.Context, p domain.PendingReview) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		a := domain.SLAAction{
+			PullRequestID: p.PullRequestID,
+			UserID:        p.UserID,
+			Action:        domain.SLAReassign,
+			AssignedAt:    p.AssignedAt,
+		}
+
+		newID, err := s.prs.ReassignReviewer(ctx, p.PullRequestID, p.UserID, "")
+		switch {
+		case err == nil:
+			a.NewUserID = newID
+		case IsCodeError(err):
+			a.Error = err.Error()
+		default:
+			return err
+		}
+
+		added, err := s.repo.AddSLAAction(ctx, a)
+		if err != nil {
+			return err
+		}
+		if !added && newID != "" {
+			return errSLAActionExists
+		}
+		return nil
+	})
+}
+
+// Actions returns the recorded SLA actions, newest first.
+func (s *SLAScheduler) Actions(ctx context.Context, prID string, limit int) ([]domain.SLAAction, error) {
+	if limit <= 0 {
+		limit = defaultSLAActions
+	}
+	if limit > maxSLAActions {
+		limit = maxSLAActions
+	}
+	return s.repo.ListSLAActions(ctx, prID, limit)
+}
+
+func minutes(n int) time.Duration {
+	return time.Duration(n) * time.Minute
+}
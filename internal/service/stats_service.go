@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+const defaultStatsWindow = 30 * 24 * time.Hour
+
+type StatsRepo interface {
+	ReviewerStats(ctx context.Context, f domain.StatsFilter) ([]domain.ReviewerStats, error)
+	TeamStats(ctx context.Context, f domain.StatsFilter, roots, teams []string) ([]domain.TeamStats, error)
+	AssignmentLoads(ctx context.Context, f domain.StatsFilter) ([]domain.ReviewerLoad, error)
+}
+
+type StatsService struct {
+	repo     StatsRepo
+	teamRepo TeamRepo
+}
+
+func NewStatsService(repo StatsRepo, teamRepo TeamRepo) *StatsService {
+	return &StatsService{repo: repo, teamRepo: teamRepo}
+}
+
+func (s *StatsService) ReviewerStats(ctx context.Context, f domain.StatsFilter, includeSubtree bool) ([]domain.ReviewerStats, error) {
+	f, err := s.prepareFilter(ctx, f, includeSubtree)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ReviewerStats(ctx, f)
+}
+
+// TeamStats reports throughput and cycle time for f.Teams, or for every team
+// when f.Teams is empty. With includeSubtree each team also covers the PRs of
+// the teams below it.
+func (s *StatsService) TeamStats(ctx context.Context, f domain.StatsFilter, includeSubtree bool) ([]domain.TeamStats, error) {
+	f, err := s.prepareFilter(ctx, f, false)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := f.Teams
+	if len(roots) == 0 {
+		if roots, err = s.teamRepo.ListTeamNames(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var scopeRoots, scopeTeams []string
+	for _, root := range roots {
+		teams := []string{root}
+		if includeSubtree {
+			if teams, err = s.teamRepo.ListSubtree(ctx, root); err != nil {
+				return nil, fmt.Errorf("list subtree: %w", err)
+			}
+		}
+		for _, t := range teams {
+			scopeRoots = append(scopeRoots, root)
+			scopeTeams = append(scopeTeams, t)
+		}
+	}
+
+	return s.repo.TeamStats(ctx, f, scopeRoots, scopeTeams)
+}
+
+// prepareFilter fills in the default window and, with includeSubtree, adds
+// every team below the requested ones.
+func (s *StatsService) prepareFilter(ctx context.Context, f domain.StatsFilter, includeSubtree bool) (domain.StatsFilter, error) {
+	f = DefaultStatsWindow(f)
+	if !f.From.Before(f.To) {
+		return f, ErrInvalidWindow
+	}
+
+	for _, name := range f.Teams {
+		if _, err := s.teamRepo.GetTeam(ctx, name); err != nil {
+			return f, err
+		}
+	}
+
+	if includeSubtree && len(f.Teams) > 0 {
+		seen := make(map[string]bool)
+		var teams []string
+		for _, root := range f.Teams {
+			names, err := s.teamRepo.ListSubtree(ctx, root)
+			if err != nil {
+				return f, fmt.Errorf("list subtree: %w", err)
+			}
+			for _, name := range names {
+				if !seen[name] {
+					seen[name] = true
+					teams = append(teams, name)
+				}
+			}
+		}
+		f.Teams = teams
+	}
+	return f, nil
+}
+
+// DefaultStatsWindow ends an open window now and starts it 30 days before its
+// end.
+func DefaultStatsWindow(f domain.StatsFilter) domain.StatsFilter {
+	if f.To.IsZero() {
+		f.To = time.Now()
+	}
+	if f.From.IsZero() {
+		f.From = f.To.Add(-defaultStatsWindow)
+	}
+	return f
+}
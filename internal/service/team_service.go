@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"net/mail"
 
 	"github.com/egoisthemain/pr-reviewer/internal/domain"
 )
@@ -11,19 +12,63 @@ type TeamRepo interface {
 	CreateTeamWithMembers(ctx context.Context, team domain.Team) error
 	GetTeam(ctx context.Context, teamName string) (*domain.Team, error)
 	ListTeams(ctx context.Context) ([]domain.Team, error)
+	GetUser(ctx context.Context, userID string) (*domain.User, error)
+	SetUserRole(ctx context.Context, userID string, role domain.UserRole) (*domain.User, error)
+	SetShadowFraction(ctx context.Context, teamName string, fraction float64) error
+	SetReviewSLA(ctx context.Context, teamName string, sla domain.ReviewSLA) error
 	SetUserActive(ctx context.Context, userID string, isActive bool) (*domain.User, error)
+	RenameTeam(ctx context.Context, oldName, newName string) error
+	DeleteTeam(ctx context.Context, teamName string) error
+	AddMember(ctx context.Context, teamName string, u domain.User) error
+	RemoveMember(ctx context.Context, teamName, userID string) error
+	MoveUser(ctx context.Context, userID, fromTeam, toTeam string) (*domain.User, error)
+	SetUsername(ctx context.Context, userID, username string) (*domain.User, error)
+	SetEmail(ctx context.Context, userID, email string) (*domain.User, error)
+	SetWorkSchedule(ctx context.Context, userID string, ws domain.WorkSchedule) (*domain.User, error)
+	SetParent(ctx context.Context, teamName, parent string) error
+	ListSubtree(ctx context.Context, teamName string) ([]string, error)
+	ListAncestors(ctx context.Context, teamName string) ([]string, error)
+	ListTeamNames(ctx context.Context) ([]string, error)
 }
 
 type TeamService struct {
-	repo TeamRepo
+	repo   TeamRepo
+	audit  *AuditService
+	tx     Transactor
+	outbox OutboxRepo
 }
 
-func NewTeamService(repo TeamRepo) *TeamService {
-	return &TeamService{repo: repo}
+func NewTeamService(repo TeamRepo, audit *AuditService, tx Transactor, outbox OutboxRepo) *TeamService {
+	return &TeamService{repo: repo, audit: audit, tx: tx, outbox: outbox}
 }
 
 func (s *TeamService) CreateTeam(ctx context.Context, t domain.Team) error {
-	return s.repo.CreateTeamWithMembers(ctx, t)
+	if err := checkShadowFraction(t.ShadowFraction); err != nil {
+		return err
+	}
+	if err := checkReviewSLA(t.ReviewSLA); err != nil {
+		return err
+	}
+	for _, u := range t.Members {
+		if u.Role != "" {
+			if err := checkRole(u.Role); err != nil {
+				return err
+			}
+		}
+		if err := checkEmail(u.Email); err != nil {
+			return err
+		}
+		if err := u.WorkSchedule.Check(); err != nil {
+			return ErrInvalidSchedule
+		}
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateTeamWithMembers(ctx, t); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditTeamCreate, auditEntityTeam, t.TeamName, nil, t)
+	})
 }
 
 func (s *TeamService) GetTeam(ctx context.Context, teamName string) (*domain.Team, error) {
@@ -35,5 +80,325 @@ func (s *TeamService) ListTeams(ctx context.Context) ([]domain.Team, error) {
 }
 
 func (s *TeamService) SetUserActive(ctx context.Context, userID string, isActive bool) (*domain.User, error) {
-	return s.repo.SetUserActive(ctx, userID, isActive)
+	before, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var u *domain.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		u, err = s.repo.SetUserActive(ctx, userID, isActive)
+		if err != nil {
+			return err
+		}
+		if before.IsActive && !u.IsActive {
+			if err := emit(ctx, s.outbox, domain.DomainUserDeactivated, userID, domain.EventPayload{User: u}); err != nil {
+				return err
+			}
+		}
+		return s.audit.Record(ctx, AuditUserSetActive, auditEntityUser, userID, before, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *TeamService) SetUserRole(ctx context.Context, userID string, role domain.UserRole) (*domain.User, error) {
+	if err := checkRole(role); err != nil {
+		return nil, err
+	}
+
+	before, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var u *domain.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if u, err = s.repo.SetUserRole(ctx, userID, role); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditUserSetRole, auditEntityUser, userID, before, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// SetReviewSLA sets when the SLA scheduler reminds and replaces the reviewers
+// of the team's PRs. A reassignment threshold must come after the reminder.
+func (s *TeamService) SetReviewSLA(ctx context.Context, teamName string, sla domain.ReviewSLA) (*domain.Team, error) {
+	if err := checkReviewSLA(sla); err != nil {
+		return nil, err
+	}
+
+	before, err := s.repo.GetTeam(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *domain.Team
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetReviewSLA(ctx, teamName, sla); err != nil {
+			return err
+		}
+		if after, err = s.repo.GetTeam(ctx, teamName); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditTeamSetReviewSLA, auditEntityTeam, teamName, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+func (s *TeamService) SetShadowFraction(ctx context.Context, teamName string, fraction float64) (*domain.Team, error) {
+	if err := checkShadowFraction(fraction); err != nil {
+		return nil, err
+	}
+
+	before, err := s.repo.GetTeam(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *domain.Team
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetShadowFraction(ctx, teamName, fraction); err != nil {
+			return err
+		}
+		if after, err = s.repo.GetTeam(ctx, teamName); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditTeamSetShadowFraction, auditEntityTeam, teamName, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+func (s *TeamService) RenameTeam(ctx context.Context, oldName, newName string) (*domain.Team, error) {
+	if newName == "" {
+		return nil, ErrInvalidTeamName
+	}
+
+	var t *domain.Team
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.RenameTeam(ctx, oldName, newName); err != nil {
+			return err
+		}
+		var err error
+		if t, err = s.repo.GetTeam(ctx, newName); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditTeamRename, auditEntityTeam, newName,
+			map[string]string{"team_name": oldName}, map[string]string{"team_name": newName})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *TeamService) DeleteTeam(ctx context.Context, teamName string) error {
+	before, err := s.repo.GetTeam(ctx, teamName)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteTeam(ctx, teamName); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditTeamDelete, auditEntityTeam, teamName, before, nil)
+	})
+}
+
+func (s *TeamService) AddMember(ctx context.Context, teamName string, u domain.User) (*domain.Team, error) {
+	if u.UserID == "" || u.Username == "" {
+		return nil, ErrInvalidUser
+	}
+	if u.Role != "" {
+		if err := checkRole(u.Role); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkEmail(u.Email); err != nil {
+		return nil, err
+	}
+	if err := u.WorkSchedule.Check(); err != nil {
+		return nil, ErrInvalidSchedule
+	}
+
+	var t *domain.Team
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.AddMember(ctx, teamName, u); err != nil {
+			return err
+		}
+		var err error
+		if t, err = s.repo.GetTeam(ctx, teamName); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditTeamAddMember, auditEntityTeam, teamName, nil, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *TeamService) RemoveMember(ctx context.Context, teamName, userID string) (*domain.Team, error) {
+	before, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var t *domain.Team
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.RemoveMember(ctx, teamName, userID); err != nil {
+			return err
+		}
+		if t, err = s.repo.GetTeam(ctx, teamName); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditTeamRemoveMember, auditEntityTeam, teamName, before, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *TeamService) MoveUser(ctx context.Context, userID, fromTeam, toTeam string) (*domain.User, error) {
+	if fromTeam != "" && fromTeam == toTeam {
+		return nil, ErrSameTeam
+	}
+
+	before, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var u *domain.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if u, err = s.repo.MoveUser(ctx, userID, fromTeam, toTeam); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditUserMove, auditEntityUser, userID, before, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *TeamService) SetUsername(ctx context.Context, userID, username string) (*domain.User, error) {
+	if username == "" {
+		return nil, ErrInvalidUser
+	}
+
+	before, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var u *domain.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if u, err = s.repo.SetUsername(ctx, userID, username); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditUserSetUsername, auditEntityUser, userID, before, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// SetEmail sets the address notifications are mailed to; an empty one stops
+// them.
+func (s *TeamService) SetEmail(ctx context.Context, userID, email string) (*domain.User, error) {
+	if err := checkEmail(email); err != nil {
+		return nil, err
+	}
+
+	before, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var u *domain.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if u, err = s.repo.SetEmail(ctx, userID, email); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditUserSetEmail, auditEntityUser, userID, before, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// SetWorkSchedule sets the user's timezone and working hours; nil hours make
+// the user always available.
+func (s *TeamService) SetWorkSchedule(ctx context.Context, userID string, ws domain.WorkSchedule) (*domain.User, error) {
+	if err := ws.Check(); err != nil {
+		return nil, ErrInvalidSchedule
+	}
+
+	before, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var u *domain.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if u, err = s.repo.SetWorkSchedule(ctx, userID, ws); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditUserSetSchedule, auditEntityUser, userID, before, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// checkEmail accepts a bare address such as "a@example.com" or an empty
+// string.
+func checkEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+	if a, err := mail.ParseAddress(email); err != nil || a.Address != email {
+		return ErrInvalidEmail
+	}
+	return nil
+}
+
+func checkReviewSLA(sla domain.ReviewSLA) error {
+	if sla.ReminderMinutes < 0 || sla.ReassignMinutes < 0 ||
+		(sla.ReassignMinutes > 0 && sla.ReassignMinutes <= sla.ReminderMinutes) {
+		return ErrInvalidSLA
+	}
+	return nil
+}
+
+func checkRole(role domain.UserRole) error {
+	if role != domain.RoleMember && role != domain.RoleShadow {
+		return ErrInvalidRole
+	}
+	return nil
+}
+
+func checkShadowFraction(f float64) error {
+	if f < 0 || f > 1 {
+		return ErrInvalidShadowFraction
+	}
+	return nil
 }
@@ -0,0 +1,15 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMoveUserToSameTeam(t *testing.T) {
+	// The repository is never reached: any call on it would panic.
+	s := NewTeamService(struct{ TeamRepo }{}, nil, noTx{}, nil)
+
+	if _, err := s.MoveUser(context.Background(), "u1", "backend", "backend"); err != ErrSameTeam {
+		t.Fatalf("MoveUser(backend -> backend) = %v; want ErrSameTeam", err)
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+// ancestorLevels groups the ancestors of teams by distance: the first level
+// holds their parents, the second their grandparents and so on.
+func (s *PRService) ancestorLevels(ctx context.Context, teams []domain.Team) ([][]string, error) {
+	var levels [][]string
+	seen := make(map[string]bool)
+
+	for _, t := range teams {
+		ancestors, err := s.teamRepo.ListAncestors(ctx, t.TeamName)
+		if err != nil {
+			return nil, fmt.Errorf("list ancestors: %w", err)
+		}
+
+		for i, name := range ancestors {
+			if len(levels) <= i {
+				levels = append(levels, nil)
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			levels[i] = append(levels[i], name)
+		}
+	}
+	return levels, nil
+}
+
+// subtreePool merges every team under roots, roots included, into one
+// candidate pool.
+func (s *PRService) subtreePool(ctx context.Context, roots []string) (*domain.Team, error) {
+	var teams []domain.Team
+	for _, root := range roots {
+		names, err := s.teamRepo.ListSubtree(ctx, root)
+		if err != nil {
+			return nil, fmt.Errorf("list subtree: %w", err)
+		}
+
+		for _, name := range names {
+			t, err := s.teamRepo.GetTeam(ctx, name)
+			if err != nil {
+				return nil, fmt.Errorf("load team: %w", err)
+			}
+			teams = append(teams, *t)
+		}
+	}
+	return mergeTeams(teams), nil
+}
+
+// GetTeamTree returns teamName with its subteams nested recursively.
+func (s *TeamService) GetTeamTree(ctx context.Context, teamName string) (*domain.Team, error) {
+	names, err := s.repo.ListSubtree(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return s.repo.GetTeam(ctx, teamName)
+	}
+
+	teams := make(map[string]*domain.Team, len(names))
+	for _, name := range names {
+		t, err := s.repo.GetTeam(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		teams[name] = t
+	}
+
+	// ListSubtree returns parents first, so attaching in reverse order puts
+	// every child in place before its parent is copied into the grandparent.
+	for i := len(names) - 1; i > 0; i-- {
+		t := teams[names[i]]
+		parent := teams[t.ParentTeam]
+		parent.Subteams = append([]domain.Team{*t}, parent.Subteams...)
+	}
+	return teams[teamName], nil
+}
+
+func (s *TeamService) SetParent(ctx context.Context, teamName, parent string) (*domain.Team, error) {
+	if teamName == parent {
+		return nil, ErrInvalidTeamName
+	}
+
+	before, err := s.repo.GetTeam(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *domain.Team
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetParent(ctx, teamName, parent); err != nil {
+			return err
+		}
+		if after, err = s.repo.GetTeam(ctx, teamName); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditTeamSetParent, auditEntityTeam, teamName, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+
+	"github.com/egoisthemain/pr-reviewer/internal/auth"
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+const tokenPrefix = "prr_"
+
+type TokenRepo interface {
+	CreateToken(ctx context.Context, t domain.APIToken, hash string) (*domain.APIToken, error)
+	GetTokenByHash(ctx context.Context, hash string) (*domain.APIToken, error)
+	ListTokens(ctx context.Context) ([]domain.APIToken, error)
+	RevokeToken(ctx context.Context, id int64) (*domain.APIToken, error)
+}
+
+type TokenService struct {
+	repo  TokenRepo
+	audit *AuditService
+	tx    Transactor
+	// adminToken, when set, is accepted as an admin token without being
+	// stored, so that the first real tokens can be created.
+	adminToken string
+	// jwt verifies gateway-issued JWTs; nil disables them.
+	jwt *auth.Verifier
+}
+
+func NewTokenService(repo TokenRepo, audit *AuditService, tx Transactor, adminToken string, jwt *auth.Verifier) *TokenService {
+	return &TokenService{repo: repo, audit: audit, tx: tx, adminToken: adminToken, jwt: jwt}
+}
+
+// Create issues a new token. The secret is returned only here; the database
+// keeps its hash.
+func (s *TokenService) Create(ctx context.Context, t domain.APIToken) (string, *domain.APIToken, error) {
+	switch t.Role {
+	case domain.TokenAdmin, domain.TokenService:
+	case domain.TokenUser:
+		if t.UserID == "" {
+			return "", nil, ErrInvalidToken
+		}
+	default:
+		return "", nil, ErrInvalidToken
+	}
+	if t.Name == "" {
+		return "", nil, ErrInvalidToken
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	secret := tokenPrefix + hex.EncodeToString(b)
+
+	var created *domain.APIToken
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if created, err = s.repo.CreateToken(ctx, t, hashToken(secret)); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditTokenCreate, auditEntityToken, created.Name, nil, created)
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return secret, created, nil
+}
+
+func (s *TokenService) List(ctx context.Context) ([]domain.APIToken, error) {
+	return s.repo.ListTokens(ctx)
+}
+
+func (s *TokenService) Revoke(ctx context.Context, id int64) (*domain.APIToken, error) {
+	var t *domain.APIToken
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if t, err = s.repo.RevokeToken(ctx, id); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditTokenRevoke, auditEntityToken, t.Name, nil, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Authenticate resolves a bearer secret to its token, or returns ErrUnauthorized.
+func (s *TokenService) Authenticate(ctx context.Context, secret string) (*domain.APIToken, error) {
+	if s.adminToken != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(s.adminToken)) == 1 {
+		return &domain.APIToken{Name: "bootstrap", Role: domain.TokenAdmin}, nil
+	}
+
+	if s.jwt != nil && auth.LooksLikeJWT(secret) {
+		return s.authenticateJWT(ctx, secret)
+	}
+
+	t, err := s.repo.GetTokenByHash(ctx, hashToken(secret))
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrUnauthorized
+	}
+	return t, nil
+}
+
+// authenticateJWT turns a verified JWT into a token acting for the user named
+// in its claims.
+func (s *TokenService) authenticateJWT(ctx context.Context, secret string) (*domain.APIToken, error) {
+	id, err := s.jwt.Verify(ctx, secret)
+	if err != nil {
+		log.Printf("jwt rejected: %v", err)
+		return nil, ErrUnauthorized
+	}
+
+	role := domain.TokenRole(id.Role)
+	switch role {
+	case domain.TokenAdmin, domain.TokenService, domain.TokenUser:
+	default:
+		log.Printf("jwt rejected: unknown role %q", id.Role)
+		return nil, ErrUnauthorized
+	}
+
+	return &domain.APIToken{Name: "jwt", Role: role, UserID: id.UserID}, nil
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
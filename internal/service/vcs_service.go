@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+	"github.com/egoisthemain/pr-reviewer/internal/reqctx"
+	"github.com/egoisthemain/pr-reviewer/internal/vcs"
+)
+
+type VCSRepo interface {
+	SetIdentity(ctx context.Context, id domain.VCSIdentity) error
+	DeleteIdentity(ctx context.Context, provider domain.VCSProvider, login string) error
+	ListIdentities(ctx context.Context, provider domain.VCSProvider) ([]domain.VCSIdentity, error)
+	ResolveIdentity(ctx context.Context, provider domain.VCSProvider, login string) (string, error)
+	RecordDelivery(ctx context.Context, provider domain.VCSProvider, deliveryID string) (bool, error)
+}
+
+// Outcomes of HandleEvent.
+const (
+	VCSProcessed = "processed"
+	VCSDuplicate = "duplicate"
+	VCSIgnored   = "ignored"
+)
+
+// VCSService turns provider webhooks into PRService calls. Providers differ
+// only in how their payloads are parsed into a domain.VCSEvent. users finds
+// the author's login for events that name the author only by ID.
+type VCSService struct {
+	repo  VCSRepo
+	pr    *PRService
+	audit *AuditService
+	tx    Transactor
+	users map[domain.VCSProvider]vcs.UserLookup
+}
+
+func NewVCSService(repo VCSRepo, pr *PRService, audit *AuditService, tx Transactor,
+	users map[domain.VCSProvider]vcs.UserLookup) *VCSService {
+	return &VCSService{repo: repo, pr: pr, audit: audit, tx: tx, users: users}
+}
+
+// VCSPullRequestID is the ID under which a provider's PR is stored.
+func VCSPullRequestID(provider domain.VCSProvider, repository string, number int) string {
+	return fmt.Sprintf("%s:%s#%d", provider, repository, number)
+}
+
+// HandleEvent applies e once per delivery. A failed delivery is forgotten so
+// that the provider's redelivery gets another chance.
+func (s *VCSService) HandleEvent(ctx context.Context, e domain.VCSEvent) (string, string, error) {
+	prID := VCSPullRequestID(e.Provider, e.Repository, e.Number)
+
+	ctx = reqctx.WithActor(ctx, "webhook:"+string(e.Provider))
+
+	// The delivery is recorded in the transaction that applies it, so a
+	// failed or interrupted delivery leaves no record and its redelivery is
+	// processed again.
+	var outcome string
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if e.DeliveryID != "" {
+			fresh, err := s.repo.RecordDelivery(ctx, e.Provider, e.DeliveryID)
+			if err != nil {
+				return err
+			}
+			if !fresh {
+				outcome = VCSDuplicate
+				return nil
+			}
+		}
+
+		var err error
+		outcome, err = s.apply(ctx, e, prID)
+		return err
+	})
+	if err != nil {
+		return "", prID, err
+	}
+	return outcome, prID, nil
+}
+
+func (s *VCSService) apply(ctx context.Context, e domain.VCSEvent, prID string) (string, error) {
+	exists, err := s.pr.Exists(ctx, prID)
+	if err != nil {
+		return "", err
+	}
+
+	switch e.Action {
+	case domain.VCSOpened:
+		if exists {
+			return VCSIgnored, nil
+		}
+		authorID, err := s.resolveAuthor(ctx, e)
+		if err != nil {
+			return "", err
+		}
+		_, _, err = s.pr.CreatePR(ctx, prID, e.Title, authorID, nil)
+		return VCSProcessed, err
+
+	case domain.VCSReopened:
+		if !exists {
+			return VCSIgnored, nil
+		}
+		_, err = s.pr.ReopenPR(ctx, prID)
+		return VCSProcessed, err
+
+	case domain.VCSMerged:
+		if !exists {
+			return VCSIgnored, nil
+		}
+		_, err = s.pr.MergePR(ctx, prID)
+		return VCSProcessed, err
+
+	case domain.VCSClosed:
+		if !exists {
+			return VCSIgnored, nil
+		}
+		_, err = s.pr.ClosePR(ctx, prID)
+		return VCSProcessed, err
+	}
+	return VCSIgnored, nil
+}
+
+// resolveAuthor maps the author of e to a user, looking the login up by the
+// provider's user ID when the payload did not carry it.
+func (s *VCSService) resolveAuthor(ctx context.Context, e domain.VCSEvent) (string, error) {
+	login := e.AuthorLogin
+	if login == "" {
+		lookup := s.users[e.Provider]
+		if lookup == nil || e.AuthorID == 0 {
+			return "", ErrUnknownIdentity
+		}
+		var err error
+		if login, err = lookup.Login(ctx, e.AuthorID); err != nil {
+			return "", err
+		}
+	}
+	return s.resolve(ctx, e.Provider, login)
+}
+
+func (s *VCSService) resolve(ctx context.Context, provider domain.VCSProvider, login string) (string, error) {
+	userID, err := s.repo.ResolveIdentity(ctx, provider, login)
+	if err != nil {
+		return "", err
+	}
+	if userID == "" {
+		return "", ErrUnknownIdentity
+	}
+	return userID, nil
+}
+
+func (s *VCSService) SetIdentity(ctx context.Context, id domain.VCSIdentity) error {
+	if err := checkProvider(id.Provider); err != nil {
+		return err
+	}
+	if id.Login == "" || id.UserID == "" {
+		return ErrInvalidIdentity
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetIdentity(ctx, id); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditVCSSetIdentity, auditEntityVCSIdentity, string(id.Provider)+":"+id.Login, nil, id)
+	})
+}
+
+func (s *VCSService) DeleteIdentity(ctx context.Context, provider domain.VCSProvider, login string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteIdentity(ctx, provider, login); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditVCSDeleteIdentity, auditEntityVCSIdentity, string(provider)+":"+login, nil, nil)
+	})
+}
+
+func (s *VCSService) ListIdentities(ctx context.Context, provider domain.VCSProvider) ([]domain.VCSIdentity, error) {
+	if provider != "" {
+		if err := checkProvider(provider); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.ListIdentities(ctx, provider)
+}
+
+func checkProvider(p domain.VCSProvider) error {
+	if p != domain.ProviderGitHub && p != domain.ProviderGitLab {
+		return ErrInvalidIdentity
+	}
+	return nil
+}
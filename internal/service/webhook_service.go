@@ -0,0 +1,345 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+const (
+	webhookBatch        = 50
+	webhookLease        = time.Minute
+	webhookTimeout      = 10 * time.Second
+	maxWebhookAttempts  = 10
+	maxWebhookDelay     = time.Hour
+	defaultDeliveryList = 50
+	maxDeliveryList     = 500
+	webhookSecretPrefix = "whsec_"
+)
+
+type WebhookRepo interface {
+	CreateSubscription(ctx context.Context, s domain.WebhookSubscription) (*domain.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, s domain.WebhookSubscription) (*domain.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	ListSubscriptions(ctx context.Context, activeOnly bool) ([]domain.WebhookSubscription, error)
+	AddDelivery(ctx context.Context, subscriptionID, eventID int64, t domain.DomainEventType, payload []byte) error
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error)
+	MarkDeliveryDelivered(ctx context.Context, id int64, code int) error
+	MarkDeliveryFailed(ctx context.Context, id int64, code int, lastError string, next time.Time) error
+	ListDeliveries(ctx context.Context, subscriptionID int64, status domain.WebhookDeliveryStatus, limit int) ([]domain.WebhookDelivery, error)
+	RedeliverDelivery(ctx context.Context, subscriptionID, id int64) error
+}
+
+// WebhookService manages outgoing webhook subscriptions and delivers events
+// to them. As an outbox sink it queues a delivery per matching subscription;
+// Run sends the queued deliveries, signed with the subscription's secret.
+type WebhookService struct {
+	repo   WebhookRepo
+	teams  TeamRepo
+	audit  *AuditService
+	tx     Transactor
+	client *http.Client
+}
+
+func NewWebhookService(repo WebhookRepo, teams TeamRepo, audit *AuditService, tx Transactor) *WebhookService {
+	return &WebhookService{repo: repo, teams: teams, audit: audit, tx: tx,
+		client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// Create adds a subscription. A secret is generated when none is given; it is
+// returned only here.
+func (s *WebhookService) Create(ctx context.Context, sub domain.WebhookSubscription) (*domain.WebhookSubscription, string, error) {
+	if err := s.validate(ctx, sub); err != nil {
+		return nil, "", err
+	}
+	if sub.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", err
+		}
+		sub.Secret = webhookSecretPrefix + hex.EncodeToString(b)
+	}
+	sub.Active = true
+
+	var created *domain.WebhookSubscription
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if created, err = s.repo.CreateSubscription(ctx, sub); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditWebhookCreate, auditEntityWebhook, strconv.FormatInt(created.ID, 10), nil, created)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return created, created.Secret, nil
+}
+
+// Update replaces the URL, filter and active flag of a subscription. An empty
+// secret keeps the current one.
+func (s *WebhookService) Update(ctx context.Context, sub domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	before, err := s.repo.GetSubscription(ctx, sub.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validate(ctx, sub); err != nil {
+		return nil, err
+	}
+	if sub.Secret == "" {
+		sub.Secret = before.Secret
+	}
+
+	var after *domain.WebhookSubscription
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if after, err = s.repo.UpdateSubscription(ctx, sub); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditWebhookUpdate, auditEntityWebhook, strconv.FormatInt(after.ID, 10), before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return after, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, id int64) error {
+	before, err := s.repo.GetSubscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeleteSubscription(ctx, id); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, AuditWebhookDelete, auditEntityWebhook, strconv.FormatInt(id, 10), before, nil)
+	})
+}
+
+func (s *WebhookService) List(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	return s.repo.ListSubscriptions(ctx, false)
+}
+
+func (s *WebhookService) validate(ctx context.Context, sub domain.WebhookSubscription) error {
+	u, err := url.Parse(sub.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhook
+	}
+	for _, t := range sub.Events {
+		if !slices.Contains(domain.DomainEventTypes, t) {
+			return ErrInvalidWebhook
+		}
+	}
+	if sub.TeamName != "" {
+		if _, err := s.teams.GetTeam(ctx, sub.TeamName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deliveries returns the delivery log of a subscription, newest first.
+func (s *WebhookService) Deliveries(ctx context.Context, id int64, status domain.WebhookDeliveryStatus, limit int) ([]domain.WebhookDelivery, error) {
+	switch status {
+	case "", domain.DeliveryPending, domain.DeliveryDelivered, domain.DeliveryFailed:
+	default:
+		return nil, ErrInvalidStatus
+	}
+	if _, err := s.repo.GetSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultDeliveryList
+	}
+	if limit > maxDeliveryList {
+		limit = maxDeliveryList
+	}
+	return s.repo.ListDeliveries(ctx, id, status, limit)
+}
+
+// Redeliver sends a logged delivery again.
+func (s *WebhookService) Redeliver(ctx context.Context, id, deliveryID int64) error {
+	return s.repo.RedeliverDelivery(ctx, id, deliveryID)
+}
+
+func (s *WebhookService) Name() string { return "webhooks" }
+
+// Deliver queues e for every active subscription that wants it.
+func (s *WebhookService) Deliver(ctx context.Context, e domain.OutboxEvent) error {
+	subs, err := s.repo.ListSubscriptions(ctx, true)
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(domain.WebhookPayload{
+		EventID:   e.ID,
+		Event:     e.Type,
+		CreatedAt: e.CreatedAt,
+		Data:      e.Payload,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	var teams []string
+	for _, sub := range subs {
+		if len(sub.Events) > 0 && !slices.Contains(sub.Events, e.Type) {
+			continue
+		}
+		if sub.TeamName != "" {
+			if teams == nil {
+				if teams, err = s.eventTeams(ctx, e.Payload); err != nil {
+					return err
+				}
+			}
+			if !slices.Contains(teams, sub.TeamName) {
+				continue
+			}
+		}
+		if err := s.repo.AddDelivery(ctx, sub.ID, e.ID, e.Type, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventTeams returns the teams an event belongs to: those of the PR author or
+// of the user it is about.
+func (s *WebhookService) eventTeams(ctx context.Context, p domain.EventPayload) ([]string, error) {
+	switch {
+	case p.PullRequest != nil:
+		u, err := s.teams.GetUser(ctx, p.PullRequest.AuthorID)
+		if err != nil {
+			return nil, err
+		}
+		return append([]string{}, u.Teams...), nil
+	case p.User != nil:
+		return append([]string{}, p.User.Teams...), nil
+	}
+	return []string{}, nil
+}
+
+// Run sends due deliveries every interval until ctx is done.
+func (s *WebhookService) Run(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			for {
+				n, err := s.sendDue(ctx)
+				if err != nil {
+					log.Printf("webhook deliveries: %v", err)
+				}
+				if err != nil || n < webhookBatch {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (s *WebhookService) sendDue(ctx context.Context) (int, error) {
+	due, err := s.repo.ClaimDeliveries(ctx, webhookBatch, webhookLease)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(due) == 0 {
+		return 0, nil
+	}
+
+	list, err := s.repo.ListSubscriptions(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	subs := make(map[int64]*domain.WebhookSubscription, len(list))
+	for i := range list {
+		subs[list[i].ID] = &list[i]
+	}
+
+	for _, d := range due {
+		sub, ok := subs[d.SubscriptionID]
+		if !ok {
+			// Deleted after the claim, and its deliveries with it, or
+			// deactivated, and its deliveries wait until it is active again.
+			continue
+		}
+
+		code, err := s.send(ctx, sub, d)
+		if err == nil {
+			err = s.repo.MarkDeliveryDelivered(ctx, d.ID, code)
+		} else {
+			var next time.Time
+			if d.Attempts+1 < maxWebhookAttempts {
+				next = time.Now().Add(webhookDelay(d.Attempts + 1))
+			} else {
+				log.Printf("webhook delivery %d to subscription %d failed: %v", d.ID, sub.ID, err)
+			}
+			err = s.repo.MarkDeliveryFailed(ctx, d.ID, code, err.Error(), next)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// send posts a delivery. The body is signed with HMAC-SHA256 over the raw
+// body, hex-encoded in X-Signature-256 as "sha256=<hex>".
+func (s *WebhookService) send(ctx context.Context, sub *domain.WebhookSubscription, d domain.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	mac := hmac.New(sha256.New, []byte(sub.Secret))
+	mac.Write(d.Payload)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pr-reviewer-webhooks")
+	req.Header.Set("X-Event-Type", string(d.EventType))
+	req.Header.Set("X-Delivery-ID", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookDelay doubles from ten seconds up to maxWebhookDelay.
+func webhookDelay(attempts int) time.Duration {
+	d := 10 * time.Second << attempts
+	if d <= 0 || d > maxWebhookDelay {
+		return maxWebhookDelay
+	}
+	return d
+}
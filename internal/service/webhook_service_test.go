@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/egoisthemain/pr-reviewer/internal/domain"
+)
+
+// memWebhookRepo keeps subscriptions and deliveries in memory. Pending
+// deliveries are returned by every claim, like a lease that has run out.
+type memWebhookRepo struct {
+	WebhookRepo
+	subs       []domain.WebhookSubscription
+	deliveries []domain.WebhookDelivery
+	listLimit  int
+}
+
+func (r *memWebhookRepo) GetSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error) {
+	for _, s := range r.subs {
+		if s.ID == id {
+			return &s, nil
+		}
+	}
+	return nil, ErrInvalidWebhook
+}
+
+func (r *memWebhookRepo) ListSubscriptions(ctx context.Context, activeOnly bool) ([]domain.WebhookSubscription, error) {
+	var out []domain.WebhookSubscription
+	for _, s := range r.subs {
+		if s.Active || !activeOnly {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+func (r *memWebhookRepo) AddDelivery(ctx context.Context, subscriptionID, eventID int64, t domain.DomainEventType, payload []byte) error {
+	r.deliveries = append(r.deliveries, domain.WebhookDelivery{
+		ID: int64(len(r.deliveries) + 1), SubscriptionID: subscriptionID, EventID: eventID,
+		EventType: t, Payload: payload, Status: domain.DeliveryPending,
+	})
+	return nil
+}
+
+func (r *memWebhookRepo) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	var out []domain.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.Status == domain.DeliveryPending && len(out) < limit {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (r *memWebhookRepo) MarkDeliveryDelivered(ctx context.Context, id int64, code int) error {
+	d := &r.deliveries[id-1]
+	d.Status = domain.DeliveryDelivered
+	d.Attempts++
+	d.LastStatusCode = code
+	d.LastError = ""
+	return nil
+}
+
+func (r *memWebhookRepo) MarkDeliveryFailed(ctx context.Context, id int64, code int, lastError string, next time.Time) error {
+	d := &r.deliveries[id-1]
+	d.Attempts++
+	d.LastStatusCode = code
+	d.LastError = lastError
+	if next.IsZero() {
+		d.Status = domain.DeliveryFailed
+	} else {
+		d.NextAttemptAt = next
+	}
+	return nil
+}
+
+func (r *memWebhookRepo) ListDeliveries(ctx context.Context, subscriptionID int64, status domain.WebhookDeliveryStatus, limit int) ([]domain.WebhookDelivery, error) {
+	r.listLimit = limit
+	var out []domain.WebhookDelivery
+	for i := len(r.deliveries) - 1; i >= 0; i-- {
+		d := r.deliveries[i]
+		if d.SubscriptionID == subscriptionID && (status == "" || d.Status == status) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+// hookReceiver answers with the queued statuses, then 200, and keeps the
+// requests it got.
+type hookReceiver struct {
+	mu       sync.Mutex
+	statuses []int
+	headers  []http.Header
+	bodies   [][]byte
+}
+
+func newHookReceiver(t *testing.T, statuses ...int) (*hookReceiver, string) {
+	t.Helper()
+	h := &hookReceiver{statuses: statuses}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.headers = append(h.headers, r.Header.Clone())
+		h.bodies = append(h.bodies, body)
+		status := http.StatusOK
+		if len(h.statuses) > 0 {
+			status, h.statuses = h.statuses[0], h.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return h, srv.URL
+}
+
+func (h *hookReceiver) requests() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.bodies)
+}
+
+func newWebhookTest(t *testing.T, statuses ...int) (*WebhookService, *memWebhookRepo, *hookReceiver) {
+	t.Helper()
+	h, url := newHookReceiver(t, statuses...)
+	repo := &memWebhookRepo{subs: []domain.WebhookSubscription{
+		{ID: 1, URL: url, Secret: "whsec_test", Active: true},
+	}}
+	return NewWebhookService(repo, chatTestUsers, nil, noTx{}), repo, h
+}
+
+func TestWebhookDeliverySignature(t *testing.T) {
+	s, repo, h := newWebhookTest(t)
+
+	pr := domain.PullRequest{PullRequestID: "pr1", PullRequestName: "Add cache", AuthorID: "u1"}
+	e := domain.OutboxEvent{ID: 7, Type: domain.DomainPRCreated, AggregateID: "pr1",
+		Payload: domain.EventPayload{PullRequest: &pr}}
+	if err := s.Deliver(context.Background(), e); err != nil {
+		t.Fatalf("Deliver(): %v", err)
+	}
+	if h.requests() != 0 {
+		t.Fatal("Deliver() posted directly instead of queueing")
+	}
+	if _, err := s.sendDue(context.Background()); err != nil {
+		t.Fatalf("sendDue(): %v", err)
+	}
+
+	if h.requests() != 1 {
+		t.Fatalf("receiver got %d requests; want 1", h.requests())
+	}
+	body, hdr := h.bodies[0], h.headers[0]
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write(body)
+	if got, want := hdr.Get("X-Signature-256"), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Fatalf("X-Signature-256 = %q; want %q", got, want)
+	}
+	if hdr.Get("X-Event-Type") != string(domain.DomainPRCreated) || hdr.Get("X-Delivery-ID") != "1" ||
+		hdr.Get("Content-Type") != "application/json" {
+		t.Fatalf("headers = %v", hdr)
+	}
+
+	var p domain.WebhookPayload
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatalf("body: %v", err)
+	}
+	if p.EventID != 7 || p.Event != domain.DomainPRCreated || p.Data.PullRequest.PullRequestID != "pr1" {
+		t.Fatalf("payload = %+v", p)
+	}
+	if d := repo.deliveries[0]; d.Status != domain.DeliveryDelivered || d.LastStatusCode != http.StatusOK {
+		t.Fatalf("delivery = %+v; want DELIVERED with 200", d)
+	}
+}
+
+func TestWebhookDeliveryRetriesAfterServerError(t *testing.T) {
+	s, repo, h := newWebhookTest(t, http.StatusBadGateway)
+	repo.AddDelivery(context.Background(), 1, 7, domain.DomainPRMerged, []byte(`{"event_id":7}`))
+
+	before := time.Now()
+	if _, err := s.sendDue(context.Background()); err != nil {
+		t.Fatalf("sendDue(): %v", err)
+	}
+	d := repo.deliveries[0]
+	if d.Status != domain.DeliveryPending || d.Attempts != 1 || d.LastStatusCode != http.StatusBadGateway {
+		t.Fatalf("after 502: %+v; want PENDING after 1 attempt", d)
+	}
+	if wait := d.NextAttemptAt.Sub(before); wait < webhookDelay(1) || wait > webhookDelay(1)+time.Minute {
+		t.Fatalf("next attempt in %v; want %v", wait, webhookDelay(1))
+	}
+
+	if _, err := s.sendDue(context.Background()); err != nil {
+		t.Fatalf("sendDue(): %v", err)
+	}
+	if d := repo.deliveries[0]; d.Status != domain.DeliveryDelivered || d.Attempts != 2 || h.requests() != 2 {
+		t.Fatalf("after retry: %+v, %d requests; want DELIVERED on the second attempt", d, h.requests())
+	}
+}
+
+func TestWebhookDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	s, repo, _ := newWebhookTest(t, http.StatusServiceUnavailable)
+	repo.AddDelivery(context.Background(), 1, 7, domain.DomainPRMerged, []byte(`{}`))
+	repo.deliveries[0].Attempts = maxWebhookAttempts - 1
+
+	if _, err := s.sendDue(context.Background()); err != nil {
+		t.Fatalf("sendDue(): %v", err)
+	}
+	d := repo.deliveries[0]
+	if d.Status != domain.DeliveryFailed || d.Attempts != maxWebhookAttempts ||
+		d.LastStatusCode != http.StatusServiceUnavailable || d.LastError == "" {
+		t.Fatalf("delivery = %+v; want FAILED with the last status and error", d)
+	}
+
+	entries, err := s.Deliveries(context.Background(), 1, domain.DeliveryFailed, 0)
+	if err != nil || len(entries) != 1 || entries[0].ID != d.ID {
+		t.Fatalf("Deliveries(FAILED) = %+v, %v; want the failed delivery", entries, err)
+	}
+	if repo.listLimit != defaultDeliveryList {
+		t.Fatalf("list limit = %d; want %d", repo.listLimit, defaultDeliveryList)
+	}
+	if _, err := s.Deliveries(context.Background(), 1, "LOST", 0); err != ErrInvalidStatus {
+		t.Fatalf("Deliveries(LOST) = %v; want ErrInvalidStatus", err)
+	}
+}
+
+func TestWebhookDeliverySkipsInactiveSubscription(t *testing.T) {
+	s, repo, h := newWebhookTest(t)
+	repo.AddDelivery(context.Background(), 1, 7, domain.DomainPRMerged, []byte(`{}`))
+	repo.subs[0].Active = false
+
+	if _, err := s.sendDue(context.Background()); err != nil {
+		t.Fatalf("sendDue(): %v", err)
+	}
+	if h.requests() != 0 || repo.deliveries[0].Status != domain.DeliveryPending || repo.deliveries[0].Attempts != 0 {
+		t.Fatalf("delivery = %+v, %d requests; want it held for the inactive subscription", repo.deliveries[0], h.requests())
+	}
+}
+
+func TestWebhookDelay(t *testing.T) {
+	for _, tt := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 20 * time.Second},
+		{2, 40 * time.Second},
+		{5, 320 * time.Second},
+		{9, maxWebhookDelay},
+		{100, maxWebhookDelay},
+	} {
+		if got := webhookDelay(tt.attempts); got != tt.want {
+			t.Errorf("webhookDelay(%d) = %v; want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package vcs talks to the APIs of the VCS providers PRs come from.
+package vcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Client asks a provider to request reviews from logins on a PR. logins is
+// the full list of reviewers the PR should have; reviewers the provider has
+// beyond it are removed.
+type Client interface {
+	RequestReviewers(ctx context.Context, repository string, number int, logins []string) error
+}
+
+// UserLookup finds the login of a provider user by the provider's numeric
+// user ID, for payloads that name a user only by ID.
+type UserLookup interface {
+	Login(ctx context.Context, userID int) (string, error)
+}
+
+// Backoff controls how a failed call is retried: Attempts calls in total,
+// waiting BaseDelay, 2*BaseDelay and so on, capped at MaxDelay, with jitter.
+type Backoff struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+var DefaultBackoff = Backoff{Attempts: 4, BaseDelay: 500 * time.Millisecond, MaxDelay: 10 * time.Second}
+
+// StatusError is a response the provider rejected.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether retrying the call may help.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// IsPermanent reports whether err will not go away on retry, such as a
+// rejected token or an unknown PR.
+func IsPermanent(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && !se.Temporary()
+}
+
+// do sends the request built by newReq, retrying network errors, 429 and 5xx
+// responses according to b. newReq is called once per attempt so the body can
+// be re-read.
+func do(ctx context.Context, client *http.Client, b Backoff, newReq func() (*http.Request, error)) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt < b.Attempts; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, b.delay(attempt)); err != nil {
+				return nil, err
+			}
+		}
+
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := send(client, req.WithContext(ctx))
+		if err == nil {
+			return body, nil
+		}
+		if IsPermanent(err) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func send(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return body, nil
+}
+
+func (b Backoff) delay(attempt int) time.Duration {
+	d := b.BaseDelay << (attempt - 1)
+	if d <= 0 || d > b.MaxDelay {
+		d = b.MaxDelay
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
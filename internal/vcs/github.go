@@ -0,0 +1,130 @@
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const DefaultGitHubURL = "https://api.github.com"
+
+type GitHubClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+	backoff Backoff
+}
+
+func NewGitHubClient(baseURL, token string, backoff Backoff) *GitHubClient {
+	if baseURL == "" {
+		baseURL = DefaultGitHubURL
+	}
+	return &GitHubClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		backoff: backoff,
+	}
+}
+
+// RequestReviewers makes logins the requested reviewers of the PR: users
+// requested on GitHub but missing from logins are removed with a DELETE, and
+// the missing ones are requested with a POST.
+func (c *GitHubClient) RequestReviewers(ctx context.Context, repository string, number int, logins []string) error {
+	url := fmt.Sprintf("%s/repos/%s/pulls/%d/requested_reviewers", c.baseURL, repository, number)
+
+	current, err := c.requestedReviewers(ctx, url)
+	if err != nil {
+		return err
+	}
+
+	want := make(map[string]bool, len(logins))
+	for _, l := range logins {
+		want[strings.ToLower(l)] = true
+	}
+	requested := make(map[string]bool, len(current))
+	var remove []string
+	for _, l := range current {
+		requested[strings.ToLower(l)] = true
+		if !want[strings.ToLower(l)] {
+			remove = append(remove, l)
+		}
+	}
+	var add []string
+	for _, l := range logins {
+		if !requested[strings.ToLower(l)] {
+			add = append(add, l)
+		}
+	}
+
+	if len(remove) > 0 {
+		if err := c.send(ctx, http.MethodDelete, url, remove); err != nil {
+			return fmt.Errorf("github remove reviewers: %w", err)
+		}
+	}
+	if len(add) > 0 {
+		if err := c.send(ctx, http.MethodPost, url, add); err != nil {
+			return fmt.Errorf("github request reviewers: %w", err)
+		}
+	}
+	return nil
+}
+
+// requestedReviewers returns the logins of the users whose review the PR
+// still waits for. Requested teams are left alone.
+func (c *GitHubClient) requestedReviewers(ctx context.Context, url string) ([]string, error) {
+	body, err := do(ctx, c.client, c.backoff, func() (*http.Request, error) {
+		return c.newRequest(http.MethodGet, url, nil)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("github list requested reviewers: %w", err)
+	}
+
+	var resp struct {
+		Users []struct {
+			Login string `json:"login"`
+		} `json:"users"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("github list requested reviewers: %w", err)
+	}
+
+	logins := make([]string, 0, len(resp.Users))
+	for _, u := range resp.Users {
+		logins = append(logins, u.Login)
+	}
+	return logins, nil
+}
+
+func (c *GitHubClient) send(ctx context.Context, method, url string, logins []string) error {
+	payload, err := json.Marshal(map[string][]string{"reviewers": logins})
+	if err != nil {
+		return err
+	}
+	_, err = do(ctx, c.client, c.backoff, func() (*http.Request, error) {
+		return c.newRequest(method, url, payload)
+	})
+	return err
+}
+
+func (c *GitHubClient) newRequest(method, url string, payload []byte) (*http.Request, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
@@ -0,0 +1,203 @@
+package vcs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testBackoff = Backoff{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+type recordedRequest struct {
+	Method string
+	Path   string
+	Body   string
+	Header http.Header
+}
+
+// recorder is an httptest handler that keeps every request and answers with
+// respond.
+type recorder struct {
+	mu       sync.Mutex
+	requests []recordedRequest
+	respond  func(w http.ResponseWriter, r *http.Request, body []byte)
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec.mu.Lock()
+	rec.requests = append(rec.requests, recordedRequest{
+		Method: r.Method,
+		Path:   r.URL.EscapedPath(),
+		Body:   string(body),
+		Header: r.Header.Clone(),
+	})
+	rec.mu.Unlock()
+	rec.respond(w, r, body)
+}
+
+func (rec *recorder) calls() []recordedRequest {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]recordedRequest(nil), rec.requests...)
+}
+
+func newRecorder(t *testing.T, respond func(w http.ResponseWriter, r *http.Request, body []byte)) (*recorder, string) {
+	t.Helper()
+	rec := &recorder{respond: respond}
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+	return rec, srv.URL
+}
+
+// githubReviewers serves requested_reviewers with requested as the users
+// GitHub currently waits for, applying POSTs and DELETEs to it.
+func githubReviewers(requested ...string) func(w http.ResponseWriter, r *http.Request, body []byte) {
+	var mu sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request, body []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		var req struct {
+			Reviewers []string `json:"reviewers"`
+		}
+		json.Unmarshal(body, &req)
+
+		switch r.Method {
+		case http.MethodPost:
+			requested = append(requested, req.Reviewers...)
+		case http.MethodDelete:
+			var kept []string
+			for _, l := range requested {
+				keep := true
+				for _, d := range req.Reviewers {
+					keep = keep && l != d
+				}
+				if keep {
+					kept = append(kept, l)
+				}
+			}
+			requested = kept
+		}
+
+		users := make([]map[string]string, 0, len(requested))
+		for _, l := range requested {
+			users = append(users, map[string]string{"login": l})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"users": users, "teams": []any{}})
+	}
+}
+
+func TestGitHubRequestReviewers(t *testing.T) {
+	const path = "/repos/acme/api/pulls/42/requested_reviewers"
+
+	tests := []struct {
+		name      string
+		requested []string
+		logins    []string
+		want      []recordedRequest
+	}{
+		{
+			name:      "replace",
+			requested: []string{"Alice", "carol"},
+			logins:    []string{"alice", "bob"},
+			want: []recordedRequest{
+				{Method: http.MethodGet, Path: path},
+				{Method: http.MethodDelete, Path: path, Body: `{"reviewers":["carol"]}`},
+				{Method: http.MethodPost, Path: path, Body: `{"reviewers":["bob"]}`},
+			},
+		},
+		{
+			name:      "unchanged",
+			requested: []string{"alice", "bob"},
+			logins:    []string{"bob", "alice"},
+			want:      []recordedRequest{{Method: http.MethodGet, Path: path}},
+		},
+		{
+			name:      "all removed",
+			requested: []string{"alice"},
+			logins:    nil,
+			want: []recordedRequest{
+				{Method: http.MethodGet, Path: path},
+				{Method: http.MethodDelete, Path: path, Body: `{"reviewers":["alice"]}`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, url := newRecorder(t, githubReviewers(tt.requested...))
+			c := NewGitHubClient(url+"/", "gh-token", testBackoff)
+
+			if err := c.RequestReviewers(context.Background(), "acme/api", 42, tt.logins); err != nil {
+				t.Fatalf("RequestReviewers(): %v", err)
+			}
+
+			calls := rec.calls()
+			got := make([]recordedRequest, len(calls))
+			for i, c := range calls {
+				if auth := c.Header.Get("Authorization"); auth != "Bearer gh-token" {
+					t.Errorf("%s Authorization = %q", c.Method, auth)
+				}
+				got[i] = recordedRequest{Method: c.Method, Path: c.Path, Body: trimNewline(c.Body)}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("requests = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubRetriesServerErrors(t *testing.T) {
+	serve := githubReviewers()
+	var failed bool
+	rec, url := newRecorder(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		if r.Method == http.MethodPost && !failed {
+			failed = true
+			http.Error(w, "try later", http.StatusBadGateway)
+			return
+		}
+		serve(w, r, body)
+	})
+	c := NewGitHubClient(url, "gh-token", testBackoff)
+
+	if err := c.RequestReviewers(context.Background(), "acme/api", 42, []string{"alice"}); err != nil {
+		t.Fatalf("RequestReviewers(): %v", err)
+	}
+	var posts int
+	for _, c := range rec.calls() {
+		if c.Method == http.MethodPost {
+			posts++
+		}
+	}
+	if posts != 2 {
+		t.Fatalf("POSTs = %d; want 2", posts)
+	}
+}
+
+func TestGitHubPermanentError(t *testing.T) {
+	rec, url := newRecorder(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+	})
+	c := NewGitHubClient(url, "gh-token", testBackoff)
+
+	err := c.RequestReviewers(context.Background(), "acme/api", 42, []string{"alice"})
+	if !IsPermanent(err) {
+		t.Fatalf("RequestReviewers() = %v; want a permanent error", err)
+	}
+	if n := len(rec.calls()); n != 1 {
+		t.Fatalf("requests = %d; want 1, a 404 is not retried", n)
+	}
+}
+
+func trimNewline(s string) string {
+	if n := len(s); n > 0 && s[n-1] == '\n' {
+		return s[:n-1]
+	}
+	return s
+}
@@ -0,0 +1,140 @@
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultGitLabURL = "https://gitlab.com/api/v4"
+
+type GitLabClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+	backoff Backoff
+
+	mu  sync.Mutex
+	ids map[string]int
+}
+
+func NewGitLabClient(baseURL, token string, backoff Backoff) *GitLabClient {
+	if baseURL == "" {
+		baseURL = DefaultGitLabURL
+	}
+	return &GitLabClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		backoff: backoff,
+		ids:     make(map[string]int),
+	}
+}
+
+// RequestReviewers replaces the reviewers of the merge request with logins.
+// GitLab wants numeric user IDs, which are looked up once per login.
+func (c *GitLabClient) RequestReviewers(ctx context.Context, repository string, number int, logins []string) error {
+	ids := make([]int, 0, len(logins))
+	for _, login := range logins {
+		id, err := c.userID(ctx, login)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	payload, err := json.Marshal(map[string][]int{"reviewer_ids": ids})
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("%s/projects/%s/merge_requests/%d", c.baseURL, url.PathEscape(repository), number)
+	_, err = do(ctx, c.client, c.backoff, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		c.authorize(req)
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+	if err != nil {
+		return fmt.Errorf("gitlab set reviewers: %w", err)
+	}
+	return nil
+}
+
+func (c *GitLabClient) userID(ctx context.Context, login string) (int, error) {
+	c.mu.Lock()
+	id, ok := c.ids[login]
+	c.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	u := c.baseURL + "/users?username=" + url.QueryEscape(login)
+	body, err := do(ctx, c.client, c.backoff, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.authorize(req)
+		return req, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("gitlab find user %s: %w", login, err)
+	}
+
+	var users []struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(body, &users); err != nil {
+		return 0, fmt.Errorf("gitlab find user %s: %w", login, err)
+	}
+	if len(users) == 0 {
+		return 0, &StatusError{StatusCode: http.StatusNotFound, Body: "no gitlab user " + login}
+	}
+
+	c.mu.Lock()
+	c.ids[login] = users[0].ID
+	c.mu.Unlock()
+	return users[0].ID, nil
+}
+
+// Login returns the username of the GitLab user with the given ID.
+func (c *GitLabClient) Login(ctx context.Context, userID int) (string, error) {
+	u := fmt.Sprintf("%s/users/%d", c.baseURL, userID)
+	body, err := do(ctx, c.client, c.backoff, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		c.authorize(req)
+		return req, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("gitlab get user %d: %w", userID, err)
+	}
+
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(body, &user); err != nil {
+		return "", fmt.Errorf("gitlab get user %d: %w", userID, err)
+	}
+
+	c.mu.Lock()
+	c.ids[user.Username] = userID
+	c.mu.Unlock()
+	return user.Username, nil
+}
+
+func (c *GitLabClient) authorize(req *http.Request) {
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+}
@@ -0,0 +1,108 @@
+package vcs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// gitlabUsers serves the user endpoints for the users in ids and accepts
+// merge request updates.
+func gitlabUsers(ids map[string]int) func(w http.ResponseWriter, r *http.Request, body []byte) {
+	return func(w http.ResponseWriter, r *http.Request, body []byte) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/users":
+			var users []map[string]int
+			if id, ok := ids[r.URL.Query().Get("username")]; ok {
+				users = append(users, map[string]int{"id": id})
+			}
+			json.NewEncoder(w).Encode(users)
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/users/"):
+			for login, id := range ids {
+				if r.URL.Path == fmt.Sprintf("/users/%d", id) {
+					json.NewEncoder(w).Encode(map[string]any{"id": id, "username": login})
+					return
+				}
+			}
+			http.Error(w, `{"message":"404 User Not Found"}`, http.StatusNotFound)
+		case r.Method == http.MethodPut:
+			w.Write([]byte(`{}`))
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGitLabRequestReviewers(t *testing.T) {
+	rec, url := newRecorder(t, gitlabUsers(map[string]int{"alice": 11, "bob": 12}))
+	c := NewGitLabClient(url, "gl-token", testBackoff)
+
+	for i := 0; i < 2; i++ {
+		if err := c.RequestReviewers(context.Background(), "acme/api", 7, []string{"alice", "bob"}); err != nil {
+			t.Fatalf("RequestReviewers() #%d: %v", i+1, err)
+		}
+	}
+
+	var lookups, puts int
+	for _, call := range rec.calls() {
+		if tok := call.Header.Get("PRIVATE-TOKEN"); tok != "gl-token" {
+			t.Errorf("%s %s PRIVATE-TOKEN = %q", call.Method, call.Path, tok)
+		}
+		switch call.Method {
+		case http.MethodGet:
+			lookups++
+		case http.MethodPut:
+			puts++
+			if call.Path != "/projects/acme%2Fapi/merge_requests/7" {
+				t.Errorf("PUT path = %s", call.Path)
+			}
+			if got := trimNewline(call.Body); got != `{"reviewer_ids":[11,12]}` {
+				t.Errorf("PUT body = %s", got)
+			}
+		}
+	}
+	if lookups != 2 || puts != 2 {
+		t.Fatalf("user lookups = %d, PUTs = %d; want 2 and 2, IDs are cached", lookups, puts)
+	}
+}
+
+func TestGitLabUnknownUser(t *testing.T) {
+	rec, url := newRecorder(t, gitlabUsers(map[string]int{"alice": 11}))
+	c := NewGitLabClient(url, "gl-token", testBackoff)
+
+	err := c.RequestReviewers(context.Background(), "acme/api", 7, []string{"alice", "ghost"})
+	if !IsPermanent(err) {
+		t.Fatalf("RequestReviewers() = %v; want a permanent error", err)
+	}
+	for _, call := range rec.calls() {
+		if call.Method == http.MethodPut {
+			t.Fatal("reviewers were set despite an unknown user")
+		}
+	}
+}
+
+func TestGitLabLogin(t *testing.T) {
+	rec, url := newRecorder(t, gitlabUsers(map[string]int{"alice": 1001}))
+	c := NewGitLabClient(url, "gl-token", testBackoff)
+
+	login, err := c.Login(context.Background(), 1001)
+	if err != nil || login != "alice" {
+		t.Fatalf("Login(1001) = %q, %v; want alice", login, err)
+	}
+	if _, err := c.Login(context.Background(), 1002); !IsPermanent(err) {
+		t.Fatalf("Login(1002) = %v; want a permanent error", err)
+	}
+
+	// The ID learned from Login spares the lookup by username.
+	if err := c.RequestReviewers(context.Background(), "acme/api", 7, []string{"alice"}); err != nil {
+		t.Fatalf("RequestReviewers(): %v", err)
+	}
+	for _, call := range rec.calls() {
+		if call.Path == "/users" {
+			t.Fatal("alice was looked up by username again")
+		}
+	}
+}